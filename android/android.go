@@ -0,0 +1,175 @@
+/*
+Package android implements exporting of translations to Android string resource files.
+
+Each domain is exported to its own resource directory, containing a
+'values-[qualifier]/strings.xml' file for each language. The source language is also written to
+'values/strings.xml', so that it is used as the default when no better match is available.
+
+String names are converted to valid resource names by replacing any characters other than ASCII
+letters, digits and underscores with underscores, e.g. 'homepage.title' becomes 'homepage_title'.
+Strings whose source language content contains plural forms separated by '|' characters are
+written as <plurals> resources.
+*/
+package android
+
+import (
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Qualifier converts a language code to an Android resource qualifier, e.g. 'de-ch' to 'de-rCH'.
+func Qualifier(code string) string {
+	parts := strings.SplitN(code, "-", 2)
+	if len(parts) == 1 {
+		return strings.ToLower(parts[0])
+	}
+
+	return fmt.Sprintf("%v-r%v", strings.ToLower(parts[0]), strings.ToUpper(parts[1]))
+}
+
+// ResourceName converts a string name to a valid Android resource name.
+func ResourceName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// Escapes text for use as the content of a string resource
+func escape(s string) string {
+	s = strings.NewReplacer(
+		`\`, `\\`,
+		`'`, `\'`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\t", `\t`,
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+	).Replace(s)
+
+	// A leading '@' or '?' would make Android treat the string as a reference to another resource
+	if strings.HasPrefix(s, "@") || strings.HasPrefix(s, "?") {
+		s = `\` + s
+	}
+
+	return s
+}
+
+func getTranslation(s trans.String, l trans.Language) (t trans.Translation) {
+	if t, ok := s.Translations()[l]; ok {
+		return t
+	}
+
+	return nil
+}
+
+// Writes a single string as a <string> or <plurals> resource
+func writeResource(b *strings.Builder, name, sourceText, content string) {
+	forms := trans.PluralForms(content)
+	categories := trans.PluralCategories(len(forms))
+	if len(trans.PluralForms(sourceText)) < 2 || categories == nil {
+		fmt.Fprintf(b, "    <string name=\"%v\">%v</string>\n", name, escape(content))
+		return
+	}
+
+	fmt.Fprintf(b, "    <plurals name=\"%v\">\n", name)
+	for i, f := range forms {
+		fmt.Fprintf(b, "        <item quantity=\"%v\">%v</item>\n", categories[i], escape(f))
+	}
+	fmt.Fprintf(b, "    </plurals>\n")
+}
+
+// Exports the domain to '[domain]/values-[qualifier]/strings.xml' files in dir, one for each
+// language that the domain has translations for. The source language is also written to the
+// default '[domain]/values/strings.xml' file. When skipSource is true, neither file is written for
+// the source language.
+func Export(source trans.Domain, sourceLang trans.Language, dir string, skipSource bool) (err error) {
+	files := make(map[string]*strings.Builder)
+	codes := make([]string, 0)
+
+	strs := append([]trans.String(nil), source.Strings()...)
+	sort.Slice(strs, func(i, j int) bool { return strs[i].Name() < strs[j].Name() })
+
+	for _, s := range strs {
+		sourceText := s.Name()
+		if sourceTrans := getTranslation(s, sourceLang); sourceTrans != nil {
+			sourceText = sourceTrans.Content()
+		}
+
+		for l, t := range s.Translations() {
+			b, ok := files[l.Code]
+			if !ok {
+				b = &strings.Builder{}
+				b.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<resources>\n")
+				files[l.Code] = b
+				codes = append(codes, l.Code)
+			}
+
+			writeResource(b, ResourceName(s.Name()), sourceText, t.Content())
+		}
+	}
+
+	for _, code := range codes {
+		if skipSource && code == sourceLang.Code {
+			continue
+		}
+
+		files[code].WriteString("</resources>\n")
+		content := []byte(files[code].String())
+
+		valuesDirs := []string{"values-" + Qualifier(code)}
+		if code == sourceLang.Code {
+			valuesDirs = append(valuesDirs, "values")
+		}
+
+		for _, valuesDir := range valuesDirs {
+			resDir := filepath.Join(dir, source.Name(), valuesDir)
+			err = os.MkdirAll(resDir, 0755)
+			if err != nil {
+				return err
+			}
+
+			err = ioutil.WriteFile(filepath.Join(resDir, "strings.xml"), content, 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Removes previously exported resource files from dir. Only files belonging to the given domain
+// and language are removed, where an empty domain or language matches any.
+func Remove(dir, domain, lang string) (err error) {
+	if domain == "" {
+		domain = "*"
+	}
+	pattern := "values*"
+	if lang != "" {
+		pattern = "values-" + Qualifier(lang)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, domain, pattern, "strings.xml"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// Clean up the values directory if it is now empty
+		os.Remove(filepath.Dir(file))
+	}
+
+	return nil
+}
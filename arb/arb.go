@@ -0,0 +1,334 @@
+/*
+Package arb implements reading and writing of Flutter Application Resource Bundle (.arb) files.
+
+Each file contains the translations of a single domain into a single language, and is named
+'[domain]_[locale].arb', e.g. 'app_de_CH.arb'. The file's '@@locale' entry, when present, gives its
+language. Entries whose key starts with '@' hold metadata about the message with the same name,
+e.g. '@title' for 'title'.
+
+Strings whose source language content contains plural forms separated by '|' characters are
+written as ICU plural messages with a 'count' placeholder, e.g.
+'{count, plural, one{apple} other{apples}}', and ICU plural messages are read back into the same
+form. Note that Flutter's gen-l10n tool requires message names to be valid Dart identifiers.
+*/
+package arb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Name of the placeholder holding the number that selects a plural form
+const countPlaceholder = "count"
+
+type ArbDomain struct {
+	name     string
+	Language string
+	Messages []*ArbString
+}
+
+func (ad ArbDomain) Name() string {
+	return ad.name
+}
+func (ad *ArbDomain) SetName(name string) {
+	ad.name = name
+}
+func (ad ArbDomain) Strings() []trans.String {
+	ss := make([]trans.String, len(ad.Messages))
+	for i, m := range ad.Messages {
+		ss[i] = m
+	}
+
+	return ss
+}
+
+type ArbString struct {
+	language    *trans.Language
+	Key         string
+	Value       string
+	Description string
+}
+
+func (as ArbString) Name() string {
+	return as.Key
+}
+func (as ArbString) Translations() map[trans.Language]trans.Translation {
+	ts := make(map[trans.Language]trans.Translation)
+	ts[*as.language] = as
+
+	return ts
+}
+func (as ArbString) Content() string {
+	return as.Value
+}
+
+// The parts of a message's metadata that are used
+type metadata struct {
+	Description string `json:"description"`
+}
+
+// Creates a new ArbDomain from the file at the given path. Files with neither a locale in their name
+// nor an '@@locale' entry are read as being in defaultLang.
+func NewFromFile(file, defaultLang string) (ad *ArbDomain, err error) {
+	name, lang, err := trans.InfoFromLocaleFilename(filepath.Base(file))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var root map[string]json.RawMessage
+	err = json.Unmarshal(data, &root)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v in file '%v'", err, file))
+	}
+
+	if raw, ok := root["@@locale"]; ok {
+		var locale string
+		if err = json.Unmarshal(raw, &locale); err != nil {
+			return nil, errors.New(fmt.Sprintf("Value of '@@locale' is not a string in file '%v'", file))
+		}
+		locale = strings.ToLower(strings.Replace(locale, "_", "-", -1))
+		if lang != "" && lang != locale {
+			return nil, errors.New(fmt.Sprintf("Found locale '%v' but expected '%v' in file '%v'", locale, lang, file))
+		}
+		lang = locale
+	}
+	if lang == "" {
+		lang = defaultLang
+	}
+
+	keys := make([]string, 0, len(root))
+	for k := range root {
+		if !strings.HasPrefix(k, "@") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	l := trans.Language{Code: lang}
+	ad = &ArbDomain{name: name, Language: lang}
+	for _, k := range keys {
+		var value string
+		if err = json.Unmarshal(root[k], &value); err != nil {
+			return nil, errors.New(fmt.Sprintf("Value of key '%v' is not a string in file '%v'", k, file))
+		}
+		if forms, ok := parsePlural(value); ok {
+			value = strings.Join(forms, trans.PluralSeparator)
+		}
+
+		var meta metadata
+		if raw, ok := root["@"+k]; ok {
+			if err = json.Unmarshal(raw, &meta); err != nil {
+				return nil, errors.New(fmt.Sprintf("Metadata of key '%v' is not valid in file '%v'", k, file))
+			}
+		}
+
+		ad.Messages = append(ad.Messages, &ArbString{language: &l, Key: k, Value: value, Description: meta.Description})
+	}
+
+	return ad, nil
+}
+
+// Gets the plural forms of a message made up of a single ICU plural argument, in the order of
+// trans.AllPluralCategories. ok is false if the message is not a plural message. Exact value
+// selectors '=0', '=1' and '=2' are used as the 'zero', 'one' and 'two' forms when those are absent.
+func parsePlural(message string) (forms []string, ok bool) {
+	if !strings.HasPrefix(message, "{") || !strings.HasSuffix(message, "}") {
+		return nil, false
+	}
+
+	parts := strings.SplitN(message[1:len(message)-1], ",", 3)
+	if len(parts) != 3 || strings.TrimSpace(parts[1]) != "plural" {
+		return nil, false
+	}
+
+	selected := make(map[string]string)
+	exact := make(map[string]string)
+	rest := parts[2]
+	for {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+
+		open := strings.Index(rest, "{")
+		if open < 1 {
+			return nil, false
+		}
+		selector := strings.TrimSpace(rest[:open])
+
+		// Find the matching closing brace, allowing for nested arguments
+		depth, end := 0, -1
+		for i := open; i < len(rest) && end < 0; i++ {
+			switch rest[i] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					end = i
+				}
+			}
+		}
+		if end < 0 {
+			return nil, false
+		}
+
+		switch selector {
+		case "=0":
+			exact["zero"] = rest[open+1 : end]
+		case "=1":
+			exact["one"] = rest[open+1 : end]
+		case "=2":
+			exact["two"] = rest[open+1 : end]
+		default:
+			selected[selector] = rest[open+1 : end]
+		}
+		rest = rest[end+1:]
+	}
+
+	for c, f := range exact {
+		if _, ok := selected[c]; !ok {
+			selected[c] = f
+		}
+	}
+	if _, ok := selected["other"]; !ok || len(selected) < 2 {
+		return nil, false
+	}
+
+	for _, c := range trans.AllPluralCategories {
+		if f, ok := selected[c]; ok {
+			forms = append(forms, f)
+			delete(selected, c)
+		}
+	}
+	if len(selected) > 0 {
+		// Unknown selectors, e.g. an 'offset:' or exact values other than 0, 1 and 2
+		return nil, false
+	}
+
+	return forms, true
+}
+
+func getTranslation(s trans.String, l trans.Language) (t trans.Translation) {
+	if t, ok := s.Translations()[l]; ok {
+		return t
+	}
+
+	return nil
+}
+
+// Gets the message that content should be written as, and whether it is a plural message. Content
+// is written as an ICU plural message when the source text contains plural forms.
+func message(sourceText, content string) (msg string, plural bool) {
+	forms := trans.PluralForms(content)
+	categories := trans.PluralCategories(len(forms))
+	if len(trans.PluralForms(sourceText)) < 2 || categories == nil {
+		return content, false
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "{%v, plural,", countPlaceholder)
+	for i, f := range forms {
+		fmt.Fprintf(&b, " %v{%v}", categories[i], f)
+	}
+	b.WriteString("}")
+
+	return b.String(), true
+}
+
+// Encodes v as indented JSON, without escaping HTML characters
+func encode(v interface{}, prefix string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(prefix, "  ")
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// Exports the domain to a '[domain]_[locale].arb' file for each language that it has translations
+// for. Plural messages are followed by an '@' metadata entry describing their 'count' placeholder.
+// When skipSource is true, no file is written for the source language.
+func Export(source trans.Domain, sourceLang trans.Language, dir string, skipSource bool) (err error) {
+	// Create output directory
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	pluralMeta := map[string]interface{}{
+		"placeholders": map[string]interface{}{
+			countPlaceholder: map[string]string{"type": "int"},
+		},
+	}
+	meta, err := encode(pluralMeta, "  ")
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string][]string)
+
+	strs := append([]trans.String(nil), source.Strings()...)
+	sort.Slice(strs, func(i, j int) bool { return strs[i].Name() < strs[j].Name() })
+
+	for _, s := range strs {
+		sourceText := s.Name()
+		if sourceTrans := getTranslation(s, sourceLang); sourceTrans != nil {
+			sourceText = sourceTrans.Content()
+		}
+
+		for l, t := range s.Translations() {
+			if _, ok := files[l.Code]; !ok {
+				locale, _ := encode(trans.UnderscoreLocale(l.Code), "")
+				files[l.Code] = []string{fmt.Sprintf("  \"@@locale\": %v", locale)}
+			}
+
+			msg, plural := message(sourceText, t.Content())
+			key, _ := encode(s.Name(), "")
+			value, _ := encode(msg, "")
+			files[l.Code] = append(files[l.Code], fmt.Sprintf("  %v: %v", key, value))
+			if plural {
+				metaKey, _ := encode("@"+s.Name(), "")
+				files[l.Code] = append(files[l.Code], fmt.Sprintf("  %v: %v", metaKey, meta))
+			}
+		}
+	}
+
+	for code, entries := range files {
+		if skipSource && code == sourceLang.Code {
+			continue
+		}
+
+		content := "{\n" + strings.Join(entries, ",\n") + "\n}\n"
+
+		fileName := fmt.Sprintf("%v_%v.arb", source.Name(), trans.UnderscoreLocale(code))
+		err = ioutil.WriteFile(filepath.Join(dir, fileName), []byte(content), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Removes previously exported ARB files from the given directory. Only files belonging to the given
+// domain and language are removed, where an empty domain or language matches any.
+func Remove(dir, domain, lang string) (err error) {
+	return trans.RemoveLocaleFiles(dir, "arb", domain, lang)
+}
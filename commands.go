@@ -1,14 +1,22 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/toolani/go-translation-api/config"
 	"github.com/toolani/go-translation-api/datastore"
+	"github.com/toolani/go-translation-api/importer"
+	"github.com/toolani/go-translation-api/sheet"
+	"github.com/toolani/go-translation-api/tmx"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Command interface {
@@ -28,13 +36,22 @@ const (
 	cmdHelp         = "help"
 	cmdImport       = "import"
 	cmdInitDb       = "init-db"
+	cmdPurgeArchive = "purge-archive"
 	cmdRemoveDb     = "remove-db"
+	cmdReport       = "report"
 	cmdServe        = "serve"
+	cmdSheetExport  = "sheet-export"
+	cmdSheetImport  = "sheet-import"
+	cmdTmxExport    = "tmx-export"
+	cmdTmxImport    = "tmx-import"
+	cmdTokenCreate  = "token-create"
+	cmdTokenDelete  = "token-delete"
+	cmdTokenList    = "token-list"
 )
 
 // Gets list of available commands
 func availableCommands() []string {
-	return []string{cmdHelp, cmdExport, cmdImport, cmdInitDb, cmdRemoveDb, cmdServe}
+	return []string{cmdHelp, cmdExport, cmdImport, cmdInitDb, cmdPurgeArchive, cmdRemoveDb, cmdReport, cmdServe, cmdSheetExport, cmdSheetImport, cmdTmxExport, cmdTmxImport, cmdTokenCreate, cmdTokenDelete, cmdTokenList}
 }
 
 func getDatastore(c config.Config) (ds *datastore.DataStore) {
@@ -43,6 +60,10 @@ func getDatastore(c config.Config) (ds *datastore.DataStore) {
 	checkFatal(err)
 	ds, err = datastore.New(db, c.DB.Driver)
 	checkFatal(err)
+	ds.SourceLanguage = c.XLIFF.SourceLanguage
+	// Commands only change translations by importing them from files
+	ds.RevisionSource = datastore.RevisionSourceImport
+	ds.RevisionAuthor = importer.Author()
 
 	return ds
 }
@@ -60,23 +81,302 @@ func initDb(c config.Config) {
 	fmt.Println("Successfully migrated the database to version", dbVersion)
 }
 
-// Exports all translation domains to XLIFF
+// Exports all translation domains to files for each of the configured export targets
 func export(c config.Config) {
 	ds := getDatastore(c)
 
 	domains, err := ds.GetDomainList()
 	checkFatal(err)
 
-	fmt.Printf("Exporting %v translation domains to: %v\n", len(domains), c.XLIFF.ExportPath)
+	for _, t := range c.Export.Targets {
+		fmt.Printf("Exporting %v translation domains as %v to: %v\n", len(domains), t.Format, t.Path)
 
-	for _, dom := range domains {
-		err = ds.ExportDomain(dom.Name(), c.XLIFF.ExportPath)
+		for _, dom := range domains {
+			if !t.IncludesDomain(dom.Name()) {
+				continue
+			}
+
+			err = ds.ExportDomain(dom.Name(), t)
+			checkFatal(err)
+
+			fmt.Printf("Exported domain '%v'\n", dom.Name())
+		}
+	}
+}
+
+// Exports translations to a CSV or XLSX spreadsheet. The first argument after the command is the path
+// of the file to write, and any further arguments are the names of the domains to export. All
+// domains are exported if none are given.
+func sheetExport(c config.Config) {
+	args := flag.Args()[1:]
+	if len(args) < 1 {
+		checkFatal(errors.New("The sheet-export command requires the path of the file to write"))
+	}
+	file, names := args[0], args[1:]
+
+	ds := getDatastore(c)
+
+	if len(names) == 0 {
+		domains, err := ds.GetDomainList()
+		checkFatal(err)
+		for _, dom := range domains {
+			names = append(names, dom.Name())
+		}
+	}
+
+	s := sheet.New()
+	for _, name := range names {
+		d, err := ds.GetFullDomain(name)
+		if err == sql.ErrNoRows {
+			checkFatal(errors.New(fmt.Sprintf("Domain '%v' does not exist in database", name)))
+		}
+		checkFatal(err)
+
+		l, err := ds.GetSourceLanguage(name)
+		checkFatal(err)
+
+		s.AddDomain(d, l)
+		fmt.Printf("Exported domain '%v'\n", name)
+	}
+
+	checkFatal(s.WriteFile(file))
+	fmt.Printf("Exported %v strings to: %v\n", len(s.Rows), file)
+}
+
+// Imports translations from a CSV or XLSX spreadsheet, as written by sheet-export. The argument after
+// the command is the path of the file to read. Each cell whose content differs from the database is
+// listed, as is each row that could not be imported. The sheet is imported in a single transaction,
+// which is rolled back if any row is refused.
+func sheetImport(c config.Config) {
+	args := flag.Args()[1:]
+	if len(args) != 1 {
+		checkFatal(errors.New("The sheet-import command requires the path of the file to read"))
+	}
+
+	s, err := sheet.NewFromFile(args[0])
+	checkFatal(err)
+
+	ds := getDatastore(c)
+	err = ds.Begin()
+	checkFatal(err)
+
+	changes, refused, err := importer.ImportSheet(ds, s)
+	if err == nil && len(refused) > 0 {
+		for _, r := range refused {
+			fmt.Fprintln(os.Stderr, "Refused", r)
+		}
+		err = errors.New(fmt.Sprintf("%v rows were refused", len(refused)))
+	}
+	if err != nil {
+		if rbErr := ds.Rollback(); rbErr != nil {
+			fmt.Fprintln(os.Stderr, "Error:", rbErr)
+		}
+		checkFatal(errors.New(fmt.Sprintf("%v (the import was rolled back, no changes were made)", err)))
+	}
+
+	err = ds.Commit()
+	checkFatal(err)
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	fmt.Printf("Imported %v changed translations from %v rows\n", len(changes), len(s.Rows))
+}
+
+// Exports translations to a TMX file. The first argument after the command is the path of the file
+// to write, and any further arguments are the names of the domains to export. All domains are
+// exported if none are given.
+func tmxExport(c config.Config) {
+	args := flag.Args()[1:]
+	if len(args) < 1 {
+		checkFatal(errors.New("The tmx-export command requires the path of the file to write"))
+	}
+	file, names := args[0], args[1:]
+
+	ds := getDatastore(c)
+
+	if len(names) == 0 {
+		domains, err := ds.GetDomainList()
+		checkFatal(err)
+		for _, dom := range domains {
+			names = append(names, dom.Name())
+		}
+	}
+
+	t := tmx.New(c.XLIFF.SourceLanguage)
+	for _, name := range names {
+		d, err := ds.GetFullDomain(name)
+		if err == sql.ErrNoRows {
+			checkFatal(errors.New(fmt.Sprintf("Domain '%v' does not exist in database", name)))
+		}
+		checkFatal(err)
+
+		l, err := ds.GetSourceLanguage(name)
+		checkFatal(err)
+
+		t.AddDomain(d, l)
+		fmt.Printf("Exported domain '%v'\n", name)
+	}
+
+	checkFatal(t.WriteFile(file))
+	fmt.Printf("Exported %v translation units to: %v\n", len(t.Units), file)
+}
+
+// Imports translations from a TMX file. The arguments after the command are the path of the file to
+// read and the name of the domain to import its translation units into.
+func tmxImport(c config.Config) {
+	args := flag.Args()[1:]
+	if len(args) != 2 {
+		checkFatal(errors.New("The tmx-import command requires the path of the file to read and the name of a domain"))
+	}
+	file, name := args[0], args[1]
+
+	t, err := tmx.NewFromFile(file)
+	checkFatal(err)
+
+	d := t.Domain(name)
+
+	ds := getDatastore(c)
+	err = ds.Begin()
+	checkFatal(err)
+
+	err = ds.ImportDomain(d)
+	if err != nil {
+		if rbErr := ds.Rollback(); rbErr != nil {
+			fmt.Fprintln(os.Stderr, "Error:", rbErr)
+		}
+		checkFatal(errors.New(fmt.Sprintf("%v (the import was rolled back, no changes were made)", err)))
+	}
+
+	err = ds.Commit()
+	checkFatal(err)
+
+	fmt.Printf("Imported %v strings into domain '%v'\n", len(d.Units), name)
+}
+
+// Permanently deletes strings that were archived longer ago than the retention period
+func purgeArchive(c config.Config) {
+	days := c.Archive.RetentionDays
+	args := flag.Args()[1:]
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			checkFatal(errors.New(fmt.Sprintf("Invalid number of days '%v'", args[0])))
+		}
+		days = n
+	}
+
+	ds := getDatastore(c)
+
+	count, err := ds.PurgeArchivedStrings(time.Now().AddDate(0, 0, -days))
+	checkFatal(err)
+
+	fmt.Printf("Purged %v strings archived more than %v days ago\n", count, days)
+}
+
+// Gets the report command, which prints how completely each domain is translated into each language
+// as a table, or as JSON or CSV if asJson or asCsv is true.
+func reportWithOptions(asJson, asCsv bool) CommandFunc {
+	return func(c config.Config) {
+		if asJson && asCsv {
+			checkFatal(errors.New("The report command accepts only one of the -json and -csv options"))
+		}
+
+		ds := getDatastore(c)
+		stats, err := ds.GetTranslationStats()
 		checkFatal(err)
 
-		fmt.Printf("Exported domain '%v'\n", dom.Name())
+		switch {
+		case asJson:
+			output, err := json.MarshalIndent(stats, "", "    ")
+			checkFatal(err)
+			fmt.Println(string(output))
+		case asCsv:
+			w := csv.NewWriter(os.Stdout)
+			w.Write([]string{"domain", "language", "strings", "translated", "outdated", "percent_complete"})
+			for _, st := range stats {
+				w.Write([]string{
+					st.DomainName,
+					st.LanguageCode,
+					strconv.Itoa(st.StringCount),
+					strconv.Itoa(st.TranslatedCount),
+					strconv.Itoa(st.OutdatedCount),
+					strconv.FormatFloat(st.PercentComplete, 'f', 1, 64),
+				})
+			}
+			w.Flush()
+			checkFatal(w.Error())
+		default:
+			fmt.Printf("%-20v %-10v %10v %10v %10v %10v\n", "DOMAIN", "LANGUAGE", "STRINGS", "TRANSLATED", "OUTDATED", "COMPLETE")
+			for _, st := range stats {
+				fmt.Printf("%-20v %-10v %10v %10v %10v %9.1f%%\n", st.DomainName, st.LanguageCode, st.StringCount, st.TranslatedCount, st.OutdatedCount, st.PercentComplete)
+			}
+		}
 	}
 }
 
+// Gets the token-create command, which restricts translator tokens to the given comma-separated
+// domain names, if any. The arguments after the command are the token's name and role, followed by
+// the codes of the languages that a translator token may change. The token's secret is printed, as
+// it cannot be retrieved again.
+func tokenCreateWithDomains(domainList string) CommandFunc {
+	return func(c config.Config) {
+		args := flag.Args()[1:]
+		if len(args) < 2 {
+			checkFatal(errors.New(fmt.Sprintf("The token-create command requires a name and a role (%v)", strings.Join(datastore.Roles, ", "))))
+		}
+		name, role, languages := args[0], args[1], args[2:]
+
+		var domains []string
+		if domainList != "" {
+			domains = strings.Split(domainList, ",")
+		}
+
+		ds := getDatastore(c)
+		secret, err := ds.CreateToken(name, role, languages, domains)
+		if err == datastore.ErrAlreadyExists {
+			checkFatal(errors.New(fmt.Sprintf("A token called '%v' already exists", name)))
+		}
+		checkFatal(err)
+
+		fmt.Printf("Created %v token '%v'. Its secret is shown only once:\n%v\n", role, name, secret)
+	}
+}
+
+// Lists the API tokens for the HTTP server
+func tokenList(c config.Config) {
+	ds := getDatastore(c)
+	tokens, err := ds.GetTokenList()
+	checkFatal(err)
+
+	for _, t := range tokens {
+		domains := t.DomainList
+		if t.Role == datastore.RoleTranslator && domains == "" {
+			domains = "(all domains)"
+		}
+		fmt.Printf("%-20v %-10v %-20v %-30v %v\n", t.Name, t.Role, t.LanguageList, domains, t.CreatedAt.Format(time.RFC3339))
+	}
+	fmt.Printf("%v tokens\n", len(tokens))
+}
+
+// Deletes an API token, so that it can no longer be used. The argument after the command is the
+// token's name.
+func tokenDelete(c config.Config) {
+	args := flag.Args()[1:]
+	if len(args) != 1 {
+		checkFatal(errors.New("The token-delete command requires the name of a token"))
+	}
+
+	ds := getDatastore(c)
+	err := ds.DeleteToken(args[0])
+	if err == sql.ErrNoRows {
+		checkFatal(errors.New(fmt.Sprintf("Token '%v' does not exist", args[0])))
+	}
+	checkFatal(err)
+
+	fmt.Printf("Deleted token '%v'\n", args[0])
+}
+
 // printMustForceToRemoveDb prints usage for the remove-db command
 func printMustForceToRemoveDb(c config.Config) {
 	fmt.Fprintln(os.Stderr, "The remove-db command requires the '--force' flag")
@@ -98,7 +398,8 @@ func removeDb(c config.Config) {
 // Prints a normal usage message.
 func printUsage(c config.Config) {
 	instructions := `USAGE
-    go-translation-api [-config path] [-force] command
+    go-translation-api [-config path] [-force] [-dry-run] [-json] [-csv] [-import-policy policy]
+                       [-sync] [-domains names] command
 
 DESCRIPTION
     The following commands are available:
@@ -106,12 +407,57 @@ DESCRIPTION
         init-db   - Creates or updates the required database table structure for the Translation API.
                     Must be run at least once before any of the other commands.
                     No action is taken if the database is already up to date.
+        purge-archive [days]
+                  - Permanently deletes the strings that were archived more than the config file's
+                    archive.retention_days days ago, or the given number of days ago, along with
+                    their translations.
         remove-db - Removes all tables created by the Translation API from the database.
                     All Translation API data will be deleted from the database.
                     Requires that the -force option is provided.
+        report    - Prints the number of strings of each domain, and how many of them are translated and
+                    outdated in each language, including languages without translations. With -json
+                    or -csv, the report is printed as JSON or CSV. The two options cannot be combined.
         serve     - Starts the Translation API HTTP server using the settings defined in the config file.
-        import    - Imports the content of the XLIFF files from the config file's xliff.import_path into the database.
-        export    - Exports translations from the database to XLIFF files in the config file's xliff.export_path.
+                    Every request must be authenticated with an API token, see token-create.
+        import    - Imports the content of the XLIFF, PO, JSON, YAML, .properties and ARB files from the
+                    config file's xliff.import_path and xliff.import_paths into the database.
+                    All files are imported in a single transaction, so nothing is imported if any
+                    file fails. With -dry-run, the changes are listed and then rolled back. With
+                    -json, a JSON summary of the created, updated and unchanged strings and
+                    translations is printed. -import-policy overrides the config file's
+                    xliff.import_policy, which decides how existing translations are treated.
+                    With -sync, strings missing from their domain's imported source language files
+                    are archived. Unless -force is given, nothing is imported if more than the
+                    config file's xliff.sync_threshold percent of a domain would be archived.
+        export    - Exports translations from the database to files for each of the config file's
+                    export.target blocks, or to the config file's xliff.export_path in the format
+                    given by its export.format (xliff, po, json, yaml, properties, arb, android, ios
+                    or gotext) if there are none.
+        sheet-export file [domain ...]
+                  - Exports translations from the database to a CSV or XLSX spreadsheet, depending on
+                    the file's extension, with one row per string and a column for each language.
+                    Only the given domains are exported, or all domains if none are given.
+        sheet-import file
+                  - Imports translations from a CSV or XLSX spreadsheet written by sheet-export,
+                    listing each cell whose content differs from the database. Rows whose domain or
+                    language does not exist in the database are refused, in which case they are
+                    listed and nothing is imported.
+        tmx-export file [domain ...]
+                  - Exports translations from the database to a TMX 1.4b translation memory file.
+                    Only the given domains are exported, or all domains if none are given.
+        tmx-import file domain
+                  - Imports the translation units of a TMX file into the given domain, using each
+                    unit's tuid as the name of its string. The domain is created if it does not exist.
+                    If any unit cannot be imported, no changes are made.
+        token-create name role [language ...]
+                  - Creates an API token for the HTTP server and prints its secret, which cannot be
+                    shown again. The role is one of viewer (read only), translator (may also change
+                    the translations into the given languages) or admin (may make any change).
+                    With -domains, a translator token may only access the given domains.
+        token-list
+                  - Lists the API tokens with their roles, languages and domains.
+        token-delete name
+                  - Deletes an API token, so that it can no longer be used.
         help      - Prints this help message.
 
 OPTIONS`
@@ -125,6 +471,13 @@ func printMissingCommandUsage(c config.Config) {
 	printUsage(c)
 }
 
+// Gets the import command, run with the given options.
+func importWithOptions(opts importer.Options) CommandFunc {
+	return func(c config.Config) {
+		importer.Import(c, opts)
+	}
+}
+
 // Prints a usage message indicating that the given command was not recognised.
 func printUnrecognisedCommandUsage(cmd string) CommandFunc {
 	return func(c config.Config) {
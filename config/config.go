@@ -10,6 +10,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/BurntSushi/toml"
+	"github.com/toolani/go-translation-api/format"
+	"github.com/toolani/go-translation-api/trans"
+	"github.com/toolani/go-translation-api/xliff"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,13 +21,41 @@ import (
 const (
 	DbDriverSqlite3    = "sqlite3"
 	DbDriverPostgresql = "postgres"
+
+	// Language code used as the source language when none is configured
+	DefaultSourceLanguage = "en"
+
+	// Percentage of a domain's strings that syncing may archive when none is configured
+	DefaultSyncThreshold = 25
+
+	// Number of days that archived strings are kept for when none is configured
+	DefaultRetentionDays = 90
+
+	JsonStyleFlat   = "flat"
+	JsonStyleNested = "nested"
+
+	// Existing translations are replaced by imported ones
+	ImportPolicyOverwrite = "overwrite"
+	// Existing translations are kept, only missing translations are imported
+	ImportPolicySkipExisting = "skip-existing"
+	// Only strings that do not exist yet are imported
+	ImportPolicyOnlyNewStrings = "only-new-strings"
+	// The import fails if any existing translation differs from the imported one
+	ImportPolicyFailOnConflict = "fail-on-conflict"
 )
 
+var jsonStyles = []string{JsonStyleFlat, JsonStyleNested}
+
+// ImportPolicies lists the available ImportPolicy* constants.
+var ImportPolicies = []string{ImportPolicyOverwrite, ImportPolicySkipExisting, ImportPolicyOnlyNewStrings, ImportPolicyFailOnConflict}
+
 // Config represents the parsed configuration for the translation API.
 type Config struct {
-	DB     DbConfig     `toml:"database"`
-	Server ServerConfig `toml:"server"`
-	XLIFF  XliffConfig  `toml:"xliff"`
+	DB      DbConfig      `toml:"database"`
+	Server  ServerConfig  `toml:"server"`
+	XLIFF   XliffConfig   `toml:"xliff"`
+	Export  ExportConfig  `toml:"export"`
+	Archive ArchiveConfig `toml:"archive"`
 }
 
 // valid checks if the Config is valid in its current state.
@@ -53,18 +84,89 @@ func (c *Config) valid() error {
 	if c.Server.Port < 0 {
 		return errors.New("config: server.port is invalid")
 	}
-	if len(c.XLIFF.ImportPath) == 0 {
+	if len(c.XLIFF.ImportPath) == 0 && len(c.XLIFF.ImportPaths) == 0 {
 		return errors.New("config: missing xliff.import_path value")
 	}
+	if _, err := trans.ParseFilenamePattern(c.XLIFF.FilenamePattern); err != nil {
+		return errors.New(fmt.Sprintf("config: invalid xliff.filename_pattern value. (%v)", err))
+	}
+	if c.XLIFF.SyncThreshold < 0 || c.XLIFF.SyncThreshold > 100 {
+		return errors.New("config: invalid xliff.sync_threshold value. (Must be between 0 and 100)")
+	}
+	if c.Archive.RetentionDays < 0 {
+		return errors.New("config: invalid archive.retention_days value. (Must not be negative)")
+	}
+	if !ValidImportPolicy(c.XLIFF.ImportPolicy) {
+		return errors.New(fmt.Sprintf("config: invalid xliff.import_policy value. (Must be one of: '%v')", strings.Join(ImportPolicies, ", ")))
+	}
 	if len(c.XLIFF.ExportPath) == 0 {
 		return errors.New("config: missing xliff.export_path value")
 	}
-	if _, err := os.Stat(filepath.FromSlash(c.XLIFF.ImportPath)); os.IsNotExist(err) {
-		return errors.New("xliff: import_path does not exist")
+	if len(c.XLIFF.SourceLanguage) == 0 {
+		return errors.New("config: missing xliff.source_language value")
+	}
+	if !validExportFormat(c.Export.Format) {
+		return errors.New(fmt.Sprintf("config: invalid export.format value. (Must be one of: '%v')", strings.Join(format.WritableNames(), ", ")))
+	}
+	if !validJsonStyle(c.Export.JsonStyle) {
+		return errors.New(fmt.Sprintf("config: invalid export.json_style value. (Must be one of: '%v')", strings.Join(jsonStyles, ", ")))
+	}
+	if !xliff.ValidVersion(c.Export.XliffVersion) {
+		return errors.New(fmt.Sprintf("config: invalid export.xliff_version value. (Must be one of: '%v')", strings.Join(xliff.Versions, ", ")))
+	}
+	for i, t := range c.Export.Targets {
+		if !validExportFormat(t.Format) {
+			return errors.New(fmt.Sprintf("config: invalid format value for export.target %v. (Must be one of: '%v')", i+1, strings.Join(format.WritableNames(), ", ")))
+		}
+		if len(t.Path) == 0 {
+			return errors.New(fmt.Sprintf("config: missing path value for export.target %v", i+1))
+		}
+		if !validJsonStyle(t.JsonStyle) {
+			return errors.New(fmt.Sprintf("config: invalid json_style value for export.target %v. (Must be one of: '%v')", i+1, strings.Join(jsonStyles, ", ")))
+		}
+		if !xliff.ValidVersion(t.XliffVersion) {
+			return errors.New(fmt.Sprintf("config: invalid xliff_version value for export.target %v. (Must be one of: '%v')", i+1, strings.Join(xliff.Versions, ", ")))
+		}
+	}
+	if c.XLIFF.ImportPath != "" {
+		if _, err := os.Stat(filepath.FromSlash(c.XLIFF.ImportPath)); os.IsNotExist(err) {
+			return errors.New("xliff: import_path does not exist")
+		}
+	}
+	for _, path := range c.XLIFF.ImportPaths {
+		dirs, err := filepath.Glob(filepath.FromSlash(path))
+		if err != nil {
+			return errors.New(fmt.Sprintf("xliff: invalid import_paths value '%v'. (%v)", path, err))
+		}
+		if len(dirs) == 0 {
+			return errors.New(fmt.Sprintf("xliff: import_paths value '%v' does not exist", path))
+		}
 	}
 	return nil
 }
 
+// validExportFormat checks whether name is a registered format that can be exported.
+func validExportFormat(name string) bool {
+	f, ok := format.Get(name)
+	return ok && f.Writer != nil
+}
+
+// validJsonStyle checks whether style is one of the JsonStyle* constants.
+func validJsonStyle(style string) bool {
+	for _, s := range jsonStyles {
+		if s == style {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidImportPolicy checks whether policy is one of the ImportPolicy* constants.
+func ValidImportPolicy(policy string) bool {
+	return contains(ImportPolicies, policy)
+}
+
 // DbConfig contains Database connection configuration.
 type DbConfig struct {
 	// Must currently be 'sqlite3' or 'postgres'
@@ -93,8 +195,111 @@ type ServerConfig struct {
 type XliffConfig struct {
 	// Path to import XLIFF files from
 	ImportPath string `toml:"import_path"`
+	// Further paths to import files from, which may contain wildcards, e.g.
+	// 'src/*/Resources/translations'
+	ImportPaths []string `toml:"import_paths"`
+	// Whether to also import files from subdirectories of the import paths
+	ImportRecursive bool `toml:"import_recursive"`
+	// Pattern that the names of imported files follow, e.g. '{domain}.{lang}.{ext}'
+	FilenamePattern string `toml:"filename_pattern"`
+	// How imported translations that already exist are treated, one of the ImportPolicy* constants
+	ImportPolicy string `toml:"import_policy"`
+	// Percentage of a domain's strings that syncing may archive without the -force flag
+	SyncThreshold int `toml:"sync_threshold"`
 	// Path to export XLIFF files to
 	ExportPath string `toml:"export_path"`
+	// Code of the language that translations are made from. Can be overridden per domain.
+	SourceLanguage string `toml:"source_language"`
+}
+
+// ImportDirs gets the directories to import files from: the import_path, if set, followed by those
+// matching each of the import_paths.
+func (xc XliffConfig) ImportDirs() (dirs []string, err error) {
+	if xc.ImportPath != "" {
+		dirs = append(dirs, filepath.FromSlash(xc.ImportPath))
+	}
+
+	for _, path := range xc.ImportPaths {
+		matches, err := filepath.Glob(filepath.FromSlash(path))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && info.IsDir() {
+				dirs = append(dirs, m)
+			}
+		}
+	}
+
+	return dirs, nil
+}
+
+// ArchiveConfig contains settings for archived strings.
+type ArchiveConfig struct {
+	// Number of days after which archived strings may be purged
+	RetentionDays int `toml:"retention_days"`
+}
+
+// ExportConfig contains settings for exporting translations to files.
+type ExportConfig struct {
+	// Name of the format of exported files, e.g. 'xliff'. Used, along with the xliff.export_path,
+	// when no targets are configured.
+	Format string
+	// Layout of exported JSON files, one of the JsonStyle* constants. Used by targets that do not
+	// set their own.
+	JsonStyle string `toml:"json_style"`
+	// Version of exported XLIFF files, one of the xliff.Version* constants. Used by targets that do
+	// not set their own.
+	XliffVersion string `toml:"xliff_version"`
+	// Whether to generate a Go catalog for each domain exported in 'gotext' format. Used, along with
+	// Format, when no targets are configured.
+	GoCatalog bool `toml:"go_catalog"`
+	// Whether to only export approved translations. Used, along with Format, when no targets are
+	// configured.
+	ApprovedOnly bool `toml:"approved_only"`
+	// Sets of files that translations are exported to. Set from Format when none are configured.
+	Targets []ExportTarget `toml:"target"`
+}
+
+// ExportTarget describes a set of files that translations are exported to.
+type ExportTarget struct {
+	// Name of the format of exported files, e.g. 'xliff'
+	Format string
+	// Path to export files to
+	Path string
+	// Layout of exported JSON files, one of the JsonStyle* constants
+	JsonStyle string `toml:"json_style"`
+	// Version of exported XLIFF files, one of the xliff.Version* constants
+	XliffVersion string `toml:"xliff_version"`
+	// Whether to generate a Go catalog for each domain, when exporting in 'gotext' format
+	GoCatalog bool `toml:"go_catalog"`
+	// Whether to only export approved translations. Translations that are not approved are exported
+	// with their content when they were last approved, or left out if they never were.
+	ApprovedOnly bool `toml:"approved_only"`
+	// Codes of the languages to export. All languages are exported if empty.
+	Languages []string
+	// Names of the domains to export. All domains are exported if empty.
+	Domains []string
+}
+
+// IncludesDomain checks whether the named domain is exported to the target.
+func (t *ExportTarget) IncludesDomain(name string) bool {
+	return len(t.Domains) == 0 || contains(t.Domains, name)
+}
+
+// IncludesLanguage checks whether translations in the given language are exported to the target.
+func (t *ExportTarget) IncludesLanguage(code string) bool {
+	return len(t.Languages) == 0 || contains(t.Languages, code)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Gets a connection string for this config.
@@ -121,8 +326,20 @@ func new() Config {
 			Port: 8181,
 		},
 		XLIFF: XliffConfig{
-			ImportPath: filepath.FromSlash("./xliff-in"),
-			ExportPath: filepath.FromSlash("./xliff-out"),
+			ImportPath:      filepath.FromSlash("./xliff-in"),
+			ExportPath:      filepath.FromSlash("./xliff-out"),
+			SourceLanguage:  DefaultSourceLanguage,
+			FilenamePattern: trans.DefaultFilenamePattern,
+			ImportPolicy:    ImportPolicyOverwrite,
+			SyncThreshold:   DefaultSyncThreshold,
+		},
+		Export: ExportConfig{
+			Format:       format.Xliff,
+			JsonStyle:    JsonStyleFlat,
+			XliffVersion: xliff.Version12,
+		},
+		Archive: ArchiveConfig{
+			RetentionDays: DefaultRetentionDays,
 		},
 	}
 	return c
@@ -131,14 +348,38 @@ func new() Config {
 // Loads config from a TOML file and checks its validity.
 func Load(file string) (Config, error) {
 	conf := new()
-	_, err := toml.DecodeFile(file, &conf)
+	md, err := toml.DecodeFile(file, &conf)
 	if err != nil {
 		return conf, err
 	}
 
+	// The default import_path is only used when no import_paths are configured
+	if len(conf.XLIFF.ImportPaths) > 0 && !md.IsDefined("xliff", "import_path") {
+		conf.XLIFF.ImportPath = ""
+	}
+
+	conf.setExportTargetDefaults()
+
 	if err = conf.valid(); err != nil {
 		return conf, err
 	}
 
 	return conf, nil
 }
+
+// Adds a target using export.format and xliff.export_path if no targets are configured, and sets
+// the JSON style and XLIFF version of targets that do not have their own.
+func (c *Config) setExportTargetDefaults() {
+	if len(c.Export.Targets) == 0 {
+		c.Export.Targets = []ExportTarget{{Format: c.Export.Format, Path: c.XLIFF.ExportPath, GoCatalog: c.Export.GoCatalog, ApprovedOnly: c.Export.ApprovedOnly}}
+	}
+
+	for i := range c.Export.Targets {
+		if c.Export.Targets[i].JsonStyle == "" {
+			c.Export.Targets[i].JsonStyle = c.Export.JsonStyle
+		}
+		if c.Export.Targets[i].XliffVersion == "" {
+			c.Export.Targets[i].XliffVersion = c.Export.XliffVersion
+		}
+	}
+}
@@ -1,16 +1,22 @@
 package datastore
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/toolani/go-translation-api/config"
+	"github.com/toolani/go-translation-api/format"
 	"github.com/toolani/go-translation-api/trans"
-	"github.com/toolani/go-translation-api/xliff"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -28,31 +34,95 @@ type Adapter interface {
 	// SupportsLastInsertId indicates whether the database supports the LastInsertId function on the
 	// result of an insert query.
 	SupportsLastInsertId() bool
+	ArchiveStringQuery() string
 	CreateDomainQuery() string
 	CreateLanguageQuery() string
+	CreateNoteQuery() string
+	CreateReferenceFileQuery() string
+	CreateRevisionQuery() string
 	CreateStringQuery() string
+	CreateTokenQuery() string
 	CreateTranslationQuery() string
-	DeleteStringQuery() string
+	DeleteDomainQuery() string
+	DeleteLanguageQuery() string
+	DeleteStringNotesQuery() string
+	DeleteStringReferenceFilesQuery() string
+	DeleteTokenQuery() string
 	DeleteTranslationQuery() string
 	GetAllDomainsQuery() string
 	GetAllLanguagesQuery() string
+	GetAllTokensQuery() string
+	GetArchivedStringsQuery() string
+	GetDomainApprovedContentsQuery() string
+	GetDomainNotesQuery() string
+	GetDomainReferenceFilesQuery() string
+	GetDomainSourceLanguageQuery() string
+	GetLanguageStatsQuery() string
+	GetLanguageTranslationCountQuery() string
+	GetMissingStringsQuery() string
+	GetOutdatedTranslationsQuery() string
 	GetSearchByStringNameQuery() string
 	GetSearchByTranslationContentQuery() string
 	GetSearchByAllFieldsQuery() string
 	GetSingleDomainQuery() string
 	GetSingleDomainIdQuery() string
 	GetSingleLanguageQuery() string
-	GetSingleStringIdQuery() string
-	GetSingleTranslationIdQuery() string
+	GetSingleRevisionQuery() string
+	GetSingleStringQuery() string
+	GetSingleTokenQuery() string
+	GetSingleTokenIdQuery() string
+	GetSingleTranslationQuery() string
+	GetSourceRevisionIdQuery() string
+	GetTranslationRevisionsQuery() string
+	GetTranslationStatsQuery() string
+	MarkTranslationsOutdatedQuery() string
+	PurgeArchivedStringsQuery() string
+	RenameDomainQuery() string
+	RestoreStringQuery() string
+	SetDomainSourceLanguageQuery() string
+	UpdateLanguageQuery() string
+	UpdateStringExtraDataQuery() string
 	UpdateTranslationQuery() string
+	UpdateTranslationSourceRevisionQuery() string
+	UpdateTranslationStateQuery() string
+	UpdateTranslationStatusQuery() string
 }
 
 type DataStore struct {
-	adapter     Adapter
-	db          *sqlx.DB
+	adapter Adapter
+	db      *sqlx.DB
+	// Transaction in progress, if any, which all queries are run within
+	tx          *sqlx.Tx
 	domainCache map[string]int64
 	stringCache map[StringKey]int64
-	Stats       Stats
+	// Strings that ImportDomain left untouched because they already existed
+	skippedStrings map[StringKey]bool
+	// Names of the strings read from source language files by ImportDirs, by domain name
+	sourceStrings map[string]map[string]bool
+	// Files that ImportDirs did not import, as their language does not exist in the database
+	SkippedFiles []SkippedFile
+	Stats         Stats
+	// Changes made to strings and translations by ImportDomain
+	Diff ImportDiff
+	// Code of the language used as the source language of any domain that doesn't override it
+	SourceLanguage string
+	// How ImportDomain treats translations that already exist, one of the config.ImportPolicy*
+	// constants
+	ImportPolicy string
+	// Name of the person making changes, recorded in the revisions of the translations they change
+	RevisionAuthor string
+	// How changes are being made, one of the RevisionSource* constants, recorded in the revisions
+	// of the translations they change
+	RevisionSource string
+}
+
+// queryer is implemented by both sqlx.DB and sqlx.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Get(dest interface{}, query string, args ...interface{}) error
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Select(dest interface{}, query string, args ...interface{}) error
 }
 
 type StringKey struct {
@@ -83,6 +153,175 @@ type SearchResult struct {
 	TranslationContent string `db:"translation_content"  json:"translation_content"`
 }
 
+// TranslationStats describes how completely a domain is translated into a language.
+type TranslationStats struct {
+	DomainName      string  `db:"domain_name"  json:"domain_name"`
+	LanguageCode    string  `db:"language_code"  json:"language_code"`
+	StringCount     int     `db:"string_count"  json:"string_count"`
+	TranslatedCount int     `db:"translated_count"  json:"translated_count"`
+	OutdatedCount   int     `db:"outdated_count"  json:"outdated_count"`
+	PercentComplete float64 `db:"-"  json:"percent_complete"`
+}
+
+// OutdatedTranslation is a translation whose string's source text has changed since the translation
+// was last written. PreviousSourceContent is the source text that it was made against, if known.
+type OutdatedTranslation struct {
+	DomainName            string `db:"domain_name"  json:"domain_name"`
+	StringName            string `db:"string_name"  json:"string_name"`
+	LanguageCode          string `db:"language_code"  json:"language_code"`
+	Content               string `db:"content"  json:"content"`
+	SourceContent         string `db:"source_content"  json:"source_content"`
+	PreviousSourceContent string `db:"previous_source_content"  json:"previous_source_content"`
+}
+
+// LanguageStats describes how much use is made of a language.
+type LanguageStats struct {
+	trans.Language
+	TranslationCount int                   `json:"translation_count"`
+	Domains          []DomainLanguageStats `json:"domains"`
+}
+
+// DomainLanguageStats describes how completely a single domain is translated into a language.
+type DomainLanguageStats struct {
+	DomainName      string  `db:"domain_name"  json:"domain_name"`
+	StringCount     int     `db:"string_count"  json:"string_count"`
+	TranslatedCount int     `db:"translated_count"  json:"translated_count"`
+	PercentComplete float64 `db:"-"  json:"percent_complete"`
+}
+
+// Actions recorded in an ImportDiff
+const (
+	ChangeCreated   = "created"
+	ChangeUpdated   = "updated"
+	ChangeUnchanged = "unchanged"
+	// Not imported because of the import policy
+	ChangeSkipped = "skipped"
+	// Not imported because of the import policy, although its content differs from the existing one
+	ChangeConflict = "conflict"
+	// Archived by SyncDomains
+	ChangeArchived = "archived"
+	// Restored from the archive because it was imported again
+	ChangeRestored = "restored"
+)
+
+// ImportDiff records the strings and translations that were created, updated or left unchanged by
+// importing domains. Strings themselves are only ever created, restored, unchanged, skipped or
+// archived, changes to their content are recorded as changes to their translations.
+type ImportDiff struct {
+	Strings      []StringChange      `json:"strings"`
+	Translations []TranslationChange `json:"translations"`
+}
+
+// A file that was found in an import directory but not imported
+type SkippedFile struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+func (sf SkippedFile) String() string {
+	return fmt.Sprintf("%v (%v)", sf.File, sf.Reason)
+}
+
+type StringChange struct {
+	Domain     string `json:"domain"`
+	StringName string `json:"string"`
+	Action     string `json:"action"`
+}
+
+func (sc StringChange) String() string {
+	return fmt.Sprintf("%-9v string '%v' in domain '%v'", sc.Action, sc.StringName, sc.Domain)
+}
+
+type TranslationChange struct {
+	Domain     string `json:"domain"`
+	StringName string `json:"string"`
+	Language   string `json:"language"`
+	Action     string `json:"action"`
+	// Content before the import, which is empty for created translations
+	Old string `json:"old,omitempty"`
+	New string `json:"new"`
+}
+
+func (tc TranslationChange) String() string {
+	out := fmt.Sprintf("%-9v translation of '%v' in domain '%v' [%v]: ", tc.Action, tc.StringName, tc.Domain, tc.Language)
+	if tc.Action == ChangeUpdated || tc.Action == ChangeConflict {
+		return out + fmt.Sprintf("'%v' -> '%v'", tc.Old, tc.New)
+	}
+
+	return out + fmt.Sprintf("'%v'", tc.New)
+}
+
+// ChangeCounts holds the number of items recorded with each action in an ImportDiff.
+type ChangeCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Skipped   int `json:"skipped"`
+	Conflicts int `json:"conflicts"`
+	Restored  int `json:"restored"`
+	Archived  int `json:"archived"`
+}
+
+func (cc *ChangeCounts) add(action string) {
+	switch action {
+	case ChangeCreated:
+		cc.Created++
+	case ChangeUpdated:
+		cc.Updated++
+	case ChangeUnchanged:
+		cc.Unchanged++
+	case ChangeSkipped:
+		cc.Skipped++
+	case ChangeConflict:
+		cc.Conflicts++
+	case ChangeRestored:
+		cc.Restored++
+	case ChangeArchived:
+		cc.Archived++
+	}
+}
+
+// StringCounts counts the recorded string changes by action.
+func (d ImportDiff) StringCounts() (counts ChangeCounts) {
+	for _, sc := range d.Strings {
+		counts.add(sc.Action)
+	}
+
+	return counts
+}
+
+// TranslationCounts counts the recorded translation changes by action.
+func (d ImportDiff) TranslationCounts() (counts ChangeCounts) {
+	for _, tc := range d.Translations {
+		counts.add(tc.Action)
+	}
+
+	return counts
+}
+
+// Conflicts gets the recorded translations that were not imported although their content differs
+// from that in the database.
+func (d ImportDiff) Conflicts() (conflicts []TranslationChange) {
+	for _, tc := range d.Translations {
+		if tc.Action == ChangeConflict {
+			conflicts = append(conflicts, tc)
+		}
+	}
+
+	return conflicts
+}
+
+// Archived gets the recorded strings that were archived by SyncDomains.
+func (d ImportDiff) Archived() (archived []StringChange) {
+	for _, sc := range d.Strings {
+		if sc.Action == ChangeArchived {
+			archived = append(archived, sc)
+		}
+	}
+
+	return archived
+}
+
 func (s Stats) Log(name, action string, d time.Duration) {
 	item := s[StatKey{Name: name, Action: action}]
 	item.Count++
@@ -101,6 +340,13 @@ func (s Stats) String() (out string) {
 // ErrAlreadyExists is returned when trying to add an item that would violate a uniqueness constraint.
 var ErrAlreadyExists = errors.New("Item already exists")
 
+// ErrInUse is returned when trying to delete an item that is still referenced by other items.
+var ErrInUse = errors.New("Item is still in use")
+
+// ErrInvalidTransition is returned when trying to change the status of a translation to one that it
+// may not be changed to from its current status.
+var ErrInvalidTransition = errors.New("Translation cannot be changed to this status from its current status")
+
 // Creates a new datastore using the given database connection. The driver parameter is used to
 // select the appropriate database adapter, and should be one of the config.DbDriver* constants.
 func New(db *sqlx.DB, driver string) (ds *DataStore, err error) {
@@ -110,11 +356,16 @@ func New(db *sqlx.DB, driver string) (ds *DataStore, err error) {
 	}
 
 	ds = &DataStore{
-		adapter:     adp,
-		db:          db,
-		domainCache: make(map[string]int64),
-		stringCache: make(map[StringKey]int64),
-		Stats:       make(map[StatKey]StatItem),
+		adapter:        adp,
+		db:             db,
+		domainCache:    make(map[string]int64),
+		stringCache:    make(map[StringKey]int64),
+		skippedStrings: make(map[StringKey]bool),
+		sourceStrings:  make(map[string]map[string]bool),
+		Stats:          make(map[StatKey]StatItem),
+
+		SourceLanguage: config.DefaultSourceLanguage,
+		ImportPolicy:   config.ImportPolicyOverwrite,
 	}
 
 	err = ds.adapter.PostCreate(ds.db)
@@ -125,6 +376,63 @@ func New(db *sqlx.DB, driver string) (ds *DataStore, err error) {
 	return ds, nil
 }
 
+// Gets the transaction in progress, falling back to the database when there is none
+func (ds *DataStore) conn() queryer {
+	if ds.tx != nil {
+		return ds.tx
+	}
+
+	return ds.db
+}
+
+// Begin starts a transaction. All of the datastore's queries are run within it until Commit or
+// Rollback is called.
+func (ds *DataStore) Begin() (err error) {
+	if ds.tx != nil {
+		return errors.New("A transaction is already in progress")
+	}
+
+	ds.tx, err = ds.db.Beginx()
+
+	return err
+}
+
+// Commit commits the transaction in progress.
+func (ds *DataStore) Commit() (err error) {
+	if ds.tx == nil {
+		return errors.New("No transaction in progress")
+	}
+
+	err = ds.tx.Commit()
+	ds.tx = nil
+	if err != nil {
+		ds.clearCaches()
+	}
+
+	return err
+}
+
+// Rollback discards all changes made since the transaction in progress was started. Changes
+// recorded in Diff are kept, so that they can be reported after a trial run.
+func (ds *DataStore) Rollback() (err error) {
+	if ds.tx == nil {
+		return errors.New("No transaction in progress")
+	}
+
+	err = ds.tx.Rollback()
+	ds.tx = nil
+	// Cached IDs may belong to items that were created within the transaction
+	ds.clearCaches()
+
+	return err
+}
+
+func (ds *DataStore) clearCaches() {
+	ds.domainCache = make(map[string]int64)
+	ds.stringCache = make(map[StringKey]int64)
+	ds.skippedStrings = make(map[StringKey]bool)
+}
+
 func newAdapter(driver string) (adp Adapter, err error) {
 	// Select the appropriate adapter for the driver
 	switch driver {
@@ -159,30 +467,257 @@ func (d *Domain) Strings() []trans.String {
 type String struct {
 	id           int64
 	name         string
+	info         trans.StringInfo
 	translations map[trans.Language]trans.Translation
 }
 
 func (s String) Name() string {
 	return s.name
 }
+func (s String) Info() trans.StringInfo {
+	return s.info
+}
 func (s String) Translations() map[trans.Language]trans.Translation {
 	return s.translations
 }
 
+// Actions recorded in a Revision
+const (
+	RevisionCreated       = "created"
+	RevisionUpdated       = "updated"
+	RevisionDeleted       = "deleted"
+	RevisionStatusChanged = "status_changed"
+)
+
+// Workflow statuses of translations
+const (
+	// Not yet translated, e.g. imported with the XLIFF state 'needs-translation'
+	StatusNew         = "new"
+	StatusTranslated  = "translated"
+	StatusNeedsReview = "needs_review"
+	StatusApproved    = "approved"
+	StatusRejected    = "rejected"
+)
+
+// Statuses lists the workflow statuses of translations.
+var Statuses = []string{StatusNew, StatusTranslated, StatusNeedsReview, StatusApproved, StatusRejected}
+
+// The statuses that a translation's status may be changed to from each status
+var statusTransitions = map[string][]string{
+	StatusNew:         {StatusTranslated, StatusNeedsReview},
+	StatusTranslated:  {StatusNeedsReview, StatusApproved, StatusRejected},
+	StatusNeedsReview: {StatusApproved, StatusRejected},
+	StatusApproved:    {StatusNeedsReview},
+	StatusRejected:    {StatusTranslated, StatusNeedsReview},
+}
+
+// ValidStatus checks whether status is one of the Statuses.
+func ValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// Checks whether a translation's status may be changed from one status to another
+func canTransition(from, to string) bool {
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Gets the status of an imported translation from its state. Translations without a state that
+// says otherwise are approved, as the files that they are imported from are already in use.
+func importStatus(t trans.Translation) string {
+	state := trans.StateOf(t)
+	switch {
+	case state.Approved:
+		return StatusApproved
+	case strings.HasPrefix(state.State, "needs-review"):
+		return StatusNeedsReview
+	case state.State == "new" || strings.HasPrefix(state.State, "needs-"):
+		return StatusNew
+	case state.State == "translated":
+		return StatusTranslated
+	}
+
+	return StatusApproved
+}
+
+// Sources of the changes recorded in a Revision
+const (
+	RevisionSourceApi    = "api"
+	RevisionSourceImport = "import"
+	// Content of translations that existed before revisions were recorded
+	RevisionSourceMigration = "migration"
+)
+
+// Revision records a change to a translation. The content of a deleted translation's revision is
+// its content when it was deleted.
+type Revision struct {
+	Id      int64  `db:"id"  json:"id"`
+	Content string `db:"content"  json:"content"`
+	// Status of the translation after the change
+	Status    string    `db:"status"  json:"status"`
+	Action    string    `db:"action"  json:"action"`
+	CreatedAt time.Time `db:"created_at"  json:"created_at"`
+	Author    string    `db:"author"  json:"author"`
+	Source    string    `db:"source"  json:"source"`
+}
+
+// ArchivedString is a string that has been archived, along with the content of its translations by
+// language code.
+type ArchivedString struct {
+	Name         string
+	ArchivedAt   time.Time
+	Translations map[string]string
+}
+
+// Roles of API tokens, from least to most privileged
+const (
+	// May only read
+	RoleViewer = "viewer"
+	// May also change the translations into the token's languages
+	RoleTranslator = "translator"
+	// May make any change
+	RoleAdmin = "admin"
+)
+
+// Roles lists the roles of API tokens, from least to most privileged.
+var Roles = []string{RoleViewer, RoleTranslator, RoleAdmin}
+
+// ValidRole checks whether role is one of the Roles.
+func ValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+// Gets the position of a role in Roles, or -1 if it is not one of them
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Token is an API token, used to authenticate requests to the HTTP API. Only a hash of the token's
+// secret is stored, so the secret cannot be retrieved again once the token has been created.
+type Token struct {
+	Id   int64  `db:"id"  json:"-"`
+	Name string `db:"name"  json:"name"`
+	Role string `db:"role"  json:"role"`
+	// Comma-separated codes of the languages that a translator token may change
+	LanguageList string `db:"languages"  json:"-"`
+	// Comma-separated names of the domains that a translator token is restricted to, empty if it
+	// may access every domain
+	DomainList string    `db:"domains"  json:"-"`
+	CreatedAt  time.Time `db:"created_at"  json:"created_at"`
+}
+
+// Languages gets the codes of the languages that a translator token may change.
+func (t Token) Languages() []string {
+	if t.LanguageList == "" {
+		return nil
+	}
+
+	return strings.Split(t.LanguageList, ",")
+}
+
+// Domains gets the names of the domains that a translator token is restricted to, or nil if it may
+// access every domain.
+func (t Token) Domains() []string {
+	if t.DomainList == "" {
+		return nil
+	}
+
+	return strings.Split(t.DomainList, ",")
+}
+
+// AllowsDomain checks whether the token may access the named domain.
+func (t Token) AllowsDomain(name string) bool {
+	if t.DomainList == "" {
+		return true
+	}
+
+	for _, d := range t.Domains() {
+		if d == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsLanguage checks whether the token may change translations into the language with the
+// given code. Only translator tokens are restricted to languages.
+func (t Token) AllowsLanguage(code string) bool {
+	if t.Role != RoleTranslator {
+		return true
+	}
+
+	for _, l := range t.Languages() {
+		if l == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows checks whether the token has at least the given role. Translator tokens only have the
+// translator role for their own languages, so langCode must be one of them for a translator token
+// to be allowed the translator role.
+func (t Token) Allows(role, langCode string) bool {
+	if roleRank(t.Role) < roleRank(role) {
+		return false
+	}
+	if role != RoleTranslator {
+		return true
+	}
+
+	return t.AllowsLanguage(langCode)
+}
+
 type Translation struct {
-	id      int64
-	content string
+	id       int64
+	content  string
+	state    trans.TranslationState
+	status   string
+	outdated bool
 }
 
 func (t Translation) Content() string {
 	return t.content
 }
+func (t Translation) State() trans.TranslationState {
+	return t.state
+}
+
+// Status gets the translation's workflow status, one of the Status* constants.
+func (t Translation) Status() string {
+	return t.status
+}
+
+// Outdated checks whether the source text of the translation's string has changed since the
+// translation was last written.
+func (t Translation) Outdated() bool {
+	return t.outdated
+}
+
+// NeedsReview checks whether the translation is outdated or waiting to be reviewed.
+func (t Translation) NeedsReview() bool {
+	return t.outdated || t.status == StatusNeedsReview
+}
 
 func (ds *DataStore) getLanguage(code string) (l trans.Language, err error) {
 	start := time.Now()
 	defer func() { ds.Stats.Log("language", "get", time.Since(start)) }()
 
-	err = ds.db.Get(&l, ds.adapter.GetSingleLanguageQuery(), code)
+	err = ds.conn().Get(&l, ds.adapter.GetSingleLanguageQuery(), code)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return l, errors.New(fmt.Sprintf("Language '%v' does not exist in database", code))
@@ -202,7 +737,7 @@ func (ds *DataStore) getDomainId(name string) (id int64, err error) {
 		return id, nil
 	}
 
-	row := ds.db.QueryRow(ds.adapter.GetSingleDomainIdQuery(), name)
+	row := ds.conn().QueryRow(ds.adapter.GetSingleDomainIdQuery(), name)
 	err = row.Scan(&id)
 	if err != nil {
 		return 0, err
@@ -229,17 +764,46 @@ func (ds *DataStore) createOrGetDomain(name string) (id int64, err error) {
 	return id, err
 }
 
-func (ds *DataStore) getStringId(name string, domainId int64) (id int64, err error) {
+// getString gets the ID of a string, and whether it is archived.
+func (ds *DataStore) getString(name string, domainId int64) (id int64, archived bool, err error) {
 	start := time.Now()
 	defer func() { ds.Stats.Log("string", "get", time.Since(start)) }()
 
-	row := ds.db.QueryRow(ds.adapter.GetSingleStringIdQuery(), name, domainId)
-	err = row.Scan(&id)
+	row := ds.conn().QueryRow(ds.adapter.GetSingleStringQuery(), name, domainId)
+	err = row.Scan(&id, &archived)
 	if err != nil {
-		return 0, err
+		return 0, false, err
 	}
 
-	return id, nil
+	return id, archived, nil
+}
+
+// getStringId gets the ID of a string. Archived strings are treated as though they do not exist.
+func (ds *DataStore) getStringId(name string, domainId int64) (id int64, err error) {
+	id, archived, err := ds.getString(name, domainId)
+	if err == nil && archived {
+		return 0, sql.ErrNoRows
+	}
+
+	return id, err
+}
+
+func (ds *DataStore) archiveString(id int64) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("string", "update", time.Since(start)) }()
+
+	_, err = ds.conn().Exec(ds.adapter.ArchiveStringQuery(), time.Now().UTC(), id)
+
+	return err
+}
+
+func (ds *DataStore) restoreString(id int64) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("string", "update", time.Since(start)) }()
+
+	_, err = ds.conn().Exec(ds.adapter.RestoreStringQuery(), id)
+
+	return err
 }
 
 func (ds *DataStore) createString(name string, domainId int64) (id int64, err error) {
@@ -249,41 +813,154 @@ func (ds *DataStore) createString(name string, domainId int64) (id int64, err er
 	return ds.insert(ds.adapter.CreateStringQuery(), name, domainId)
 }
 
+// createOrGetString gets the ID of a string, creating the string if it does not exist or restoring
+// it if it is archived.
 func (ds *DataStore) createOrGetString(name string, domainId int64) (id int64, err error) {
-	id, err = ds.getStringId(name, domainId)
+	id, archived, err := ds.getString(name, domainId)
 
 	if err == sql.ErrNoRows {
 		id, err = ds.createString(name, domainId)
+	} else if err == nil && archived {
+		err = ds.restoreString(id)
 	}
 
 	return id, err
 }
 
-func (ds *DataStore) getTranslationId(langId int64, stringId int64, domainId int64) (id int64, err error) {
+// getTranslation gets the ID, content and status of a translation.
+func (ds *DataStore) getTranslation(langId int64, stringId int64, domainId int64) (id int64, content string, status string, err error) {
 	start := time.Now()
 	defer func() { ds.Stats.Log("translation", "get", time.Since(start)) }()
 
-	row := ds.db.QueryRow(ds.adapter.GetSingleTranslationIdQuery(), stringId, langId, domainId)
-	err = row.Scan(&id)
+	row := ds.conn().QueryRow(ds.adapter.GetSingleTranslationQuery(), stringId, langId, domainId)
+	err = row.Scan(&id, &content, &status)
 	if err != nil {
-		return 0, err
+		return 0, "", "", err
 	}
 
-	return id, nil
+	return id, content, status, nil
 }
 
-func (ds *DataStore) createTranslation(t trans.Translation, langId int64, stringId int64, domainId int64) (id int64, err error) {
+func (ds *DataStore) createTranslation(t trans.Translation, status string, langId int64, stringId int64, domainId int64) (id int64, err error) {
 	start := time.Now()
 	defer func() { ds.Stats.Log("translation", "insert", time.Since(start)) }()
 
-	return ds.insert(ds.adapter.CreateTranslationQuery(), langId, t.Content(), stringId)
+	id, err = ds.insert(ds.adapter.CreateTranslationQuery(), langId, t.Content(), stringId, status)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, ds.addRevision(stringId, langId, t.Content(), status, RevisionCreated)
+}
+
+func (ds *DataStore) updateTranslation(t trans.Translation, status string, transId int64, langId int64, stringId int64, domainId int64) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("translation", "update", time.Since(start)) }()
+
+	_, err = ds.conn().Exec(ds.adapter.UpdateTranslationQuery(), langId, t.Content(), stringId, status, transId)
+	if err != nil {
+		return err
+	}
+
+	return ds.addRevision(stringId, langId, t.Content(), status, RevisionUpdated)
+}
+
+// deleteTranslation deletes a translation, recording its content and status at the time in a
+// revision.
+func (ds *DataStore) deleteTranslation(transId int64, content string, status string, langId int64, stringId int64) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("translation", "delete", time.Since(start)) }()
+
+	_, err = ds.conn().Exec(ds.adapter.DeleteTranslationQuery(), transId)
+	if err != nil {
+		return err
+	}
+
+	return ds.addRevision(stringId, langId, content, status, RevisionDeleted)
+}
+
+// trackSourceRevision is called after a translation is written. Translations of the string's source
+// text are not tracked, but when updated, the string's other translations are flagged outdated.
+// Other translations record the latest revision of the source text as the one that they were made
+// against, and are no longer outdated. Nothing is tracked if the source language does not exist in
+// the database, i.e. if sourceLangId is 0.
+func (ds *DataStore) trackSourceRevision(transId int64, langId int64, stringId int64, sourceLangId int64, updated bool) (err error) {
+	if sourceLangId == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() { ds.Stats.Log("translation", "update", time.Since(start)) }()
+
+	if langId == sourceLangId {
+		if updated {
+			_, err = ds.conn().Exec(ds.adapter.MarkTranslationsOutdatedQuery(), true, stringId, langId)
+		}
+		return err
+	}
+
+	var revId sql.NullInt64
+	err = ds.conn().Get(&revId, ds.adapter.GetSourceRevisionIdQuery(), stringId, sourceLangId)
+	if err != nil {
+		return err
+	}
+
+	_, err = ds.conn().Exec(ds.adapter.UpdateTranslationSourceRevisionQuery(), revId, false, transId)
+
+	return err
+}
+
+// addRevision records a change to the translation of a string into a language, made by the
+// datastore's RevisionAuthor, along with the translation's status after the change.
+func (ds *DataStore) addRevision(stringId int64, langId int64, content string, status string, action string) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("revision", "insert", time.Since(start)) }()
+
+	_, err = ds.insert(ds.adapter.CreateRevisionQuery(), stringId, langId, content, status, action, time.Now().UTC(), ds.RevisionAuthor, ds.RevisionSource)
+
+	return err
+}
+
+// setStringInfo replaces the extradata, notes and reference files of a string.
+func (ds *DataStore) setStringInfo(stringId int64, info trans.StringInfo) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("string", "update", time.Since(start)) }()
+
+	_, err = ds.conn().Exec(ds.adapter.UpdateStringExtraDataQuery(), info.ExtraData, stringId)
+	if err != nil {
+		return err
+	}
+
+	_, err = ds.conn().Exec(ds.adapter.DeleteStringNotesQuery(), stringId)
+	if err != nil {
+		return err
+	}
+	for _, n := range info.Notes {
+		_, err = ds.conn().Exec(ds.adapter.CreateNoteQuery(), stringId, n)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = ds.conn().Exec(ds.adapter.DeleteStringReferenceFilesQuery(), stringId)
+	if err != nil {
+		return err
+	}
+	for _, r := range info.References {
+		_, err = ds.conn().Exec(ds.adapter.CreateReferenceFileQuery(), stringId, r.File, r.Line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (ds *DataStore) updateTranslation(t trans.Translation, transId int64, langId int64, stringId int64, domainId int64) (err error) {
+func (ds *DataStore) setTranslationState(transId int64, state trans.TranslationState) (err error) {
 	start := time.Now()
 	defer func() { ds.Stats.Log("translation", "update", time.Since(start)) }()
 
-	_, err = ds.db.Exec(ds.adapter.UpdateTranslationQuery(), langId, t.Content(), stringId, transId)
+	_, err = ds.conn().Exec(ds.adapter.UpdateTranslationStateQuery(), state.State, state.Approved, transId)
 
 	return err
 }
@@ -302,7 +979,7 @@ func (ds *DataStore) insert(query string, args ...interface{}) (id int64, err er
 // the LastInsertId method on the insert result. The underlying database must provide support for
 // LastInsertId for this to work.
 func (ds *DataStore) insertUsingLastInsertId(query string, args ...interface{}) (id int64, err error) {
-	result, err := ds.db.Exec(query, args...)
+	result, err := ds.conn().Exec(query, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -318,7 +995,7 @@ func (ds *DataStore) insertUsingLastInsertId(query string, args ...interface{})
 // function. The adapter must provide insert queries that return an ID as their result for this to
 // work.
 func (ds *DataStore) insertUsingQueryRow(query string, args ...interface{}) (id int64, err error) {
-	err = ds.db.QueryRow(query, args...).Scan(&id)
+	err = ds.conn().QueryRow(query, args...).Scan(&id)
 
 	return id, err
 }
@@ -348,7 +1025,7 @@ func (ds *DataStore) GetLanguageList() (languages []trans.Language, err error) {
 	start := time.Now()
 	defer func() { ds.Stats.Log("language", "get", time.Since(start)) }()
 
-	err = ds.db.Select(&languages, ds.adapter.GetAllLanguagesQuery())
+	err = ds.conn().Select(&languages, ds.adapter.GetAllLanguagesQuery())
 
 	return languages, err
 }
@@ -358,7 +1035,7 @@ func (ds *DataStore) GetDomainList() (domains []trans.Domain, err error) {
 	start := time.Now()
 	defer func() { ds.Stats.Log("domain", "get", time.Since(start)) }()
 
-	rows, err := ds.db.Query(ds.adapter.GetAllDomainsQuery())
+	rows, err := ds.conn().Query(ds.adapter.GetAllDomainsQuery())
 	if err != nil {
 		return domains, err
 	}
@@ -386,12 +1063,17 @@ func (ds *DataStore) GetFullDomain(name string) (d trans.Domain, err error) {
 		DomainId      int64          `db:"domain_id"`
 		StringId      sql.NullInt64  `db:"string_id"`
 		Name          sql.NullString `db:"string_name"`
+		ExtraData     sql.NullString `db:"extradata"`
 		LanguageId    sql.NullInt64  `db:"language_id"`
 		Code          sql.NullString `db:"language_code"`
 		TranslationId sql.NullInt64  `db:"translation_id"`
 		Content       sql.NullString `db:"content"`
+		State         sql.NullString `db:"state"`
+		Approved      sql.NullBool   `db:"approved"`
+		Status        sql.NullString `db:"status"`
+		Outdated      sql.NullBool   `db:"outdated"`
 	}
-	err = ds.db.Select(&rows, ds.adapter.GetSingleDomainQuery(), name)
+	err = ds.conn().Select(&rows, ds.adapter.GetSingleDomainQuery(), name)
 	if err != nil {
 		return d, err
 	}
@@ -415,6 +1097,7 @@ func (ds *DataStore) GetFullDomain(name string) (d trans.Domain, err error) {
 		if sIdx, ok := stringIndex[r.Name.String]; !ok {
 			// Create and index the String, if it doesn't exist already
 			s = &String{id: r.StringId.Int64, name: r.Name.String, translations: make(map[trans.Language]trans.Translation)}
+			s.info.ExtraData = r.ExtraData.String
 			dom.strings = append(dom.strings, s)
 			stringIndex[r.Name.String] = i
 			i++
@@ -425,33 +1108,287 @@ func (ds *DataStore) GetFullDomain(name string) (d trans.Domain, err error) {
 		if r.LanguageId.Valid && r.Code.Valid && r.TranslationId.Valid && r.Content.Valid {
 			// If we have a translation, add it to the string
 			l := trans.Language{Id: r.LanguageId.Int64, Code: r.Code.String}
-			t := Translation{id: r.TranslationId.Int64, content: r.Content.String}
+			t := Translation{id: r.TranslationId.Int64, content: r.Content.String, status: r.Status.String, outdated: r.Outdated.Bool}
+			t.state = trans.TranslationState{State: r.State.String, Approved: r.Approved.Bool}
 
 			s.translations[l] = &t
 		}
 	}
 
+	err = ds.addStringInfo(name, dom.strings)
+	if err != nil {
+		return d, err
+	}
+
 	return &dom, nil
 }
 
-// Creates a new language
-func (ds *DataStore) CreateLanguage(code, name string) (id int64, err error) {
-	l, err := ds.getLanguage(code)
-	if err != nil && err.Error() != fmt.Sprintf("Language '%v' does not exist in database", code) {
-		// Got an error, and it wasn't 'this language doesnt exist yet'
-		return id, err
+// addStringInfo adds the notes and reference files of the named domain to its strings.
+func (ds *DataStore) addStringInfo(domainName string, strs []trans.String) (err error) {
+	byId := make(map[int64]*String)
+	for _, s := range strs {
+		byId[s.(*String).id] = s.(*String)
 	}
 
-	// Language already exists
-	if err == nil {
-		return l.Id, ErrAlreadyExists
+	var notes []struct {
+		StringId int64  `db:"string_id"`
+		Content  string `db:"content"`
 	}
-
+	err = ds.conn().Select(&notes, ds.adapter.GetDomainNotesQuery(), domainName)
+	if err != nil {
+		return err
+	}
+	for _, n := range notes {
+		if s, ok := byId[n.StringId]; ok {
+			s.info.Notes = append(s.info.Notes, n.Content)
+		}
+	}
+
+	var refs []struct {
+		StringId int64  `db:"string_id"`
+		File     string `db:"file"`
+		Line     int    `db:"line"`
+	}
+	err = ds.conn().Select(&refs, ds.adapter.GetDomainReferenceFilesQuery(), domainName)
+	if err != nil {
+		return err
+	}
+	for _, r := range refs {
+		if s, ok := byId[r.StringId]; ok {
+			s.info.References = append(s.info.References, trans.Reference{File: r.File, Line: r.Line})
+		}
+	}
+
+	return nil
+}
+
+// Creates a new language
+func (ds *DataStore) CreateLanguage(code, name string) (id int64, err error) {
+	l, err := ds.getLanguage(code)
+	if err != nil && err.Error() != fmt.Sprintf("Language '%v' does not exist in database", code) {
+		// Got an error, and it wasn't 'this language doesnt exist yet'
+		return id, err
+	}
+
+	// Language already exists
+	if err == nil {
+		return l.Id, ErrAlreadyExists
+	}
+
 	// Create the new language
 	return ds.insert(ds.adapter.CreateLanguageQuery(), code, name)
 }
 
-// Updates the translation of the string with the given name to have the given content.
+// GetLanguageStats gets a language along with counts of its translations in each domain.
+// Returns sql.ErrNoRows when the given language code cannot be found.
+func (ds *DataStore) GetLanguageStats(code string) (stats LanguageStats, err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("language", "get", time.Since(start)) }()
+
+	err = ds.conn().Get(&stats.Language, ds.adapter.GetSingleLanguageQuery(), code)
+	if err != nil {
+		return stats, err
+	}
+
+	err = ds.conn().Get(&stats.TranslationCount, ds.adapter.GetLanguageTranslationCountQuery(), stats.Id)
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Domains = make([]DomainLanguageStats, 0)
+	err = ds.conn().Select(&stats.Domains, ds.adapter.GetLanguageStatsQuery(), stats.Id)
+	if err != nil {
+		return stats, err
+	}
+
+	for i, d := range stats.Domains {
+		if d.StringCount > 0 {
+			stats.Domains[i].PercentComplete = float64(d.TranslatedCount) * 100 / float64(d.StringCount)
+		}
+	}
+
+	return stats, nil
+}
+
+// UpdateLanguage changes the code and name of an existing language. Its translations are unaffected.
+// Returns sql.ErrNoRows when the given language code cannot be found, or ErrAlreadyExists if the
+// new code is already used by another language.
+func (ds *DataStore) UpdateLanguage(code, newCode, newName string) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("language", "update", time.Since(start)) }()
+
+	var l trans.Language
+	err = ds.conn().Get(&l, ds.adapter.GetSingleLanguageQuery(), code)
+	if err != nil {
+		return err
+	}
+
+	if newCode != code {
+		var existing trans.Language
+		err = ds.conn().Get(&existing, ds.adapter.GetSingleLanguageQuery(), newCode)
+		if err == nil {
+			return ErrAlreadyExists
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	_, err = ds.conn().Exec(ds.adapter.UpdateLanguageQuery(), newCode, newName, l.Id)
+	return err
+}
+
+// DeleteLanguage deletes a language along with all of its translations. Unless force is true,
+// ErrInUse is returned instead if any translations into the language exist.
+// Returns sql.ErrNoRows when the given language code cannot be found.
+func (ds *DataStore) DeleteLanguage(code string, force bool) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("language", "delete", time.Since(start)) }()
+
+	var l trans.Language
+	err = ds.conn().Get(&l, ds.adapter.GetSingleLanguageQuery(), code)
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		var count int
+		err = ds.conn().Get(&count, ds.adapter.GetLanguageTranslationCountQuery(), l.Id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrInUse
+		}
+	}
+
+	_, err = ds.conn().Exec(ds.adapter.DeleteLanguageQuery(), l.Id)
+	return err
+}
+
+// Creates a new, empty translation domain
+func (ds *DataStore) CreateDomain(name string) (id int64, err error) {
+	id, err = ds.getDomainId(name)
+	if err != nil && err != sql.ErrNoRows {
+		return id, err
+	}
+
+	// Domain already exists
+	if err == nil {
+		return id, ErrAlreadyExists
+	}
+
+	id, err = ds.createDomain(name)
+	if err != nil {
+		return id, err
+	}
+	ds.domainCache[name] = id
+
+	return id, nil
+}
+
+// RenameDomain changes the name of an existing domain. Its strings and translations are unaffected.
+// Returns ErrAlreadyExists if a domain called newName already exists.
+func (ds *DataStore) RenameDomain(name, newName string) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("domain", "update", time.Since(start)) }()
+
+	domId, err := ds.getDomainId(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = ds.getDomainId(newName)
+	if err == nil {
+		return ErrAlreadyExists
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = ds.conn().Exec(ds.adapter.RenameDomainQuery(), newName, domId)
+	if err != nil {
+		return err
+	}
+
+	delete(ds.domainCache, name)
+	ds.domainCache[newName] = domId
+
+	return nil
+}
+
+// DeleteDomain deletes a domain along with all of its strings and their translations.
+func (ds *DataStore) DeleteDomain(name string) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("domain", "delete", time.Since(start)) }()
+
+	domId, err := ds.getDomainId(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = ds.conn().Exec(ds.adapter.DeleteDomainQuery(), domId)
+	if err != nil {
+		return err
+	}
+
+	delete(ds.domainCache, name)
+	for k := range ds.stringCache {
+		if k.DomainId == domId {
+			delete(ds.stringCache, k)
+		}
+	}
+
+	return nil
+}
+
+// GetSourceLanguage gets the language that the named domain's strings are translated from. This is
+// the domain's own source language if one has been set, otherwise the datastore's SourceLanguage.
+// If the datastore's SourceLanguage does not exist in the database, a Language with only its code
+// and an Id of 0 is returned, as there can be no translations into it.
+func (ds *DataStore) GetSourceLanguage(domainName string) (l trans.Language, err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("language", "get", time.Since(start)) }()
+
+	err = ds.conn().Get(&l, ds.adapter.GetDomainSourceLanguageQuery(), domainName)
+	if err != sql.ErrNoRows {
+		return l, err
+	}
+
+	err = ds.conn().Get(&l, ds.adapter.GetSingleLanguageQuery(), ds.SourceLanguage)
+	if err == sql.ErrNoRows {
+		return trans.Language{Code: ds.SourceLanguage}, nil
+	}
+
+	return l, err
+}
+
+// SetSourceLanguage overrides the source language of the named domain. Passing an empty language
+// code removes the override, so that the datastore's SourceLanguage is used instead.
+func (ds *DataStore) SetSourceLanguage(domainName, langCode string) (err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("domain", "update", time.Since(start)) }()
+
+	domId, err := ds.getDomainId(domainName)
+	if err != nil {
+		return err
+	}
+
+	var langId sql.NullInt64
+	if langCode != "" {
+		lang, err := ds.getLanguage(langCode)
+		if err != nil {
+			return err
+		}
+		langId = sql.NullInt64{Int64: lang.Id, Valid: true}
+	}
+
+	_, err = ds.conn().Exec(ds.adapter.SetDomainSourceLanguageQuery(), langId, domId)
+	return err
+}
+
+// Updates the translation of the string with the given name to have the given content. Translations
+// whose content is changed have the status StatusTranslated. Changing the content of the translation
+// into the domain's source language flags the string's other translations as outdated, while saving
+// any other translation, even with unchanged content, marks it as up to date.
 // When allowCreate is false, will return an error if the string does not exist or is not yet
 // translated into the given language.
 // If allowCreate is true, both the string and translation content for the given language will be
@@ -477,21 +1414,62 @@ func (ds *DataStore) CreateOrUpdateTranslation(domainName, stringName, langCode,
 		return err
 	}
 
+	sourceLang, err := ds.GetSourceLanguage(domainName)
+	if err != nil {
+		return err
+	}
+
 	t := &Translation{content: content}
-	transId, err := ds.getTranslationId(lang.Id, stringId, domId)
+	transId, old, _, err := ds.getTranslation(lang.Id, stringId, domId)
+	updated := false
 	if err != nil && !allowCreate {
 		return err
 	} else if err == sql.ErrNoRows && allowCreate {
-		_, err = ds.createTranslation(t, lang.Id, stringId, domId)
-	} else if err == nil {
-		err = ds.updateTranslation(t, transId, lang.Id, stringId, domId)
+		transId, err = ds.createTranslation(t, StatusTranslated, lang.Id, stringId, domId)
+	} else if err == nil && old != content {
+		updated = true
+		err = ds.updateTranslation(t, StatusTranslated, transId, lang.Id, stringId, domId)
+	}
+	if err != nil {
+		return err
 	}
 
-	return err
+	return ds.trackSourceRevision(transId, lang.Id, stringId, sourceLang.Id, updated)
+}
+
+// GetTranslationHistory gets the revisions of the translation of a string into a language, newest
+// first. The revisions of archived strings and deleted translations are included.
+// Returns sql.ErrNoRows when the given domain, string or language cannot be found.
+func (ds *DataStore) GetTranslationHistory(domainName, stringName, langCode string) (revs []Revision, err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("revision", "get", time.Since(start)) }()
+
+	domId, err := ds.getDomainId(domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	stringId, _, err := ds.getString(stringName, domId)
+	if err != nil {
+		return nil, err
+	}
+
+	lang, err := ds.getLanguage(langCode)
+	if err != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	revs = make([]Revision, 0)
+	err = ds.conn().Select(&revs, ds.adapter.GetTranslationRevisionsQuery(), stringId, lang.Id)
+
+	return revs, err
 }
 
-// DeleteString deletes a single string and all its associated translations.
-func (ds *DataStore) DeleteString(domainName, stringName string) (err error) {
+// RevertTranslation sets the content of the translation of a string into a language back to its
+// content in the given revision. The translation is created again if it has since been deleted.
+// Returns sql.ErrNoRows when the string cannot be found, or the revision does not belong to the
+// string's translation into the language.
+func (ds *DataStore) RevertTranslation(domainName, stringName, langCode string, revisionId int64) (err error) {
 	domId, err := ds.getDomainId(domainName)
 	if err != nil {
 		return err
@@ -502,8 +1480,204 @@ func (ds *DataStore) DeleteString(domainName, stringName string) (err error) {
 		return err
 	}
 
-	_, err = ds.db.Exec(ds.adapter.DeleteStringQuery(), stringId)
-	return err
+	lang, err := ds.getLanguage(langCode)
+	if err != nil {
+		return sql.ErrNoRows
+	}
+
+	var rev Revision
+	err = ds.conn().Get(&rev, ds.adapter.GetSingleRevisionQuery(), revisionId, stringId, lang.Id)
+	if err != nil {
+		return err
+	}
+
+	return ds.CreateOrUpdateTranslation(domainName, stringName, langCode, rev.Content, true)
+}
+
+// SetTranslationStatus changes the workflow status of the translation of a string into a language,
+// recording the change in a revision. Returns ErrInvalidTransition if the translation's status may
+// not be changed to the given status from its current status.
+// Returns sql.ErrNoRows when the given domain, string, language or translation cannot be found.
+func (ds *DataStore) SetTranslationStatus(domainName, stringName, langCode, status string) (err error) {
+	domId, err := ds.getDomainId(domainName)
+	if err != nil {
+		return err
+	}
+
+	stringId, err := ds.getStringId(stringName, domId)
+	if err != nil {
+		return err
+	}
+
+	lang, err := ds.getLanguage(langCode)
+	if err != nil {
+		return sql.ErrNoRows
+	}
+
+	transId, content, old, err := ds.getTranslation(lang.Id, stringId, domId)
+	if err != nil {
+		return err
+	}
+	if old == status {
+		return nil
+	}
+	if !canTransition(old, status) {
+		return ErrInvalidTransition
+	}
+
+	start := time.Now()
+	defer func() { ds.Stats.Log("translation", "update", time.Since(start)) }()
+
+	_, err = ds.conn().Exec(ds.adapter.UpdateTranslationStatusQuery(), status, transId)
+	if err != nil {
+		return err
+	}
+
+	return ds.addRevision(stringId, lang.Id, content, status, RevisionStatusChanged)
+}
+
+// GetOutdatedTranslations gets the translations into the language with the given code that are
+// flagged as outdated, ordered by domain and string name. Outdated translations into all languages
+// are returned if the code is empty.
+func (ds *DataStore) GetOutdatedTranslations(langCode string) (res []OutdatedTranslation, err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("translation", "get", time.Since(start)) }()
+
+	res = make([]OutdatedTranslation, 0)
+	err = ds.conn().Select(&res, ds.adapter.GetOutdatedTranslationsQuery(), ds.SourceLanguage, langCode, langCode)
+
+	return res, err
+}
+
+// GetMissingStrings gets the names of the strings of the named domain that have no translation into
+// the language with the given code, ordered by name.
+// Returns sql.ErrNoRows when the given domain or language cannot be found.
+func (ds *DataStore) GetMissingStrings(domainName, langCode string) (names []string, err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("string", "get", time.Since(start)) }()
+
+	_, err = ds.getDomainId(domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	var lang trans.Language
+	err = ds.conn().Get(&lang, ds.adapter.GetSingleLanguageQuery(), langCode)
+	if err != nil {
+		return nil, err
+	}
+
+	names = make([]string, 0)
+	err = ds.conn().Select(&names, ds.adapter.GetMissingStringsQuery(), lang.Id, domainName)
+
+	return names, err
+}
+
+// GetTranslationStats gets the stats of each domain for each language in the database, ordered by
+// domain name and language code. Languages without any translations are included with no translated
+// strings.
+func (ds *DataStore) GetTranslationStats() (stats []TranslationStats, err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("translation", "stats", time.Since(start)) }()
+
+	stats = make([]TranslationStats, 0)
+	err = ds.conn().Select(&stats, ds.adapter.GetTranslationStatsQuery())
+	if err != nil {
+		return nil, err
+	}
+
+	for i, st := range stats {
+		if st.StringCount > 0 {
+			stats[i].PercentComplete = float64(st.TranslatedCount) * 100 / float64(st.StringCount)
+		}
+	}
+
+	return stats, nil
+}
+
+// ArchiveString archives a single string. Archived strings and their translations are kept in the
+// database, but are left out of GetFullDomain and so of exports, until restored by RestoreString
+// or purged by PurgeArchivedStrings.
+func (ds *DataStore) ArchiveString(domainName, stringName string) (err error) {
+	domId, err := ds.getDomainId(domainName)
+	if err != nil {
+		return err
+	}
+
+	stringId, err := ds.getStringId(stringName, domId)
+	if err != nil {
+		return err
+	}
+
+	return ds.archiveString(stringId)
+}
+
+// RestoreString restores an archived string along with its translations.
+// Returns sql.ErrNoRows when the string cannot be found or is not archived.
+func (ds *DataStore) RestoreString(domainName, stringName string) (err error) {
+	domId, err := ds.getDomainId(domainName)
+	if err != nil {
+		return err
+	}
+
+	stringId, archived, err := ds.getString(stringName, domId)
+	if err != nil {
+		return err
+	}
+	if !archived {
+		return sql.ErrNoRows
+	}
+
+	return ds.restoreString(stringId)
+}
+
+// GetArchivedStrings gets the archived strings of the named domain, ordered by name.
+// Returns sql.ErrNoRows when the given domain cannot be found.
+func (ds *DataStore) GetArchivedStrings(domainName string) (strs []ArchivedString, err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("string", "get", time.Since(start)) }()
+
+	_, err = ds.getDomainId(domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Name       string         `db:"string_name"`
+		ArchivedAt time.Time      `db:"archived_at"`
+		Code       sql.NullString `db:"language_code"`
+		Content    sql.NullString `db:"content"`
+	}
+	err = ds.conn().Select(&rows, ds.adapter.GetArchivedStringsQuery(), domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	strs = make([]ArchivedString, 0)
+	for _, r := range rows {
+		if len(strs) == 0 || strs[len(strs)-1].Name != r.Name {
+			strs = append(strs, ArchivedString{Name: r.Name, ArchivedAt: r.ArchivedAt, Translations: make(map[string]string)})
+		}
+		if r.Code.Valid && r.Content.Valid {
+			strs[len(strs)-1].Translations[r.Code.String] = r.Content.String
+		}
+	}
+
+	return strs, nil
+}
+
+// PurgeArchivedStrings permanently deletes the strings that were archived before the given time,
+// along with their translations, and returns how many were deleted.
+func (ds *DataStore) PurgeArchivedStrings(before time.Time) (count int64, err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("string", "delete", time.Since(start)) }()
+
+	result, err := ds.conn().Exec(ds.adapter.PurgeArchivedStringsQuery(), before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
 }
 
 // DeleteTranslation deletes a single translation.
@@ -523,15 +1697,30 @@ func (ds *DataStore) DeleteTranslation(domainName, stringName, langCode string)
 		return err
 	}
 
-	transId, err := ds.getTranslationId(lang.Id, stringId, domId)
+	transId, content, status, err := ds.getTranslation(lang.Id, stringId, domId)
 	if err != nil {
 		return err
 	}
 
-	_, err = ds.db.Exec(ds.adapter.DeleteTranslationQuery(), transId)
-	return err
+	return ds.deleteTranslation(transId, content, status, lang.Id, stringId)
 }
 
+// ImportDomain imports the strings and translations of a domain, creating or updating them as
+// needed. The notes, reference files and extradata of each string are replaced by those of the
+// imported string, unless it has none. Translation states are only replaced by the states of
+// imported translations that have one, i.e. that implement trans.StatefulTranslation. Created and
+// updated translations are given a status that follows from their state, see importStatus.
+// Updating the source language translation of a string flags its other translations as outdated,
+// unless they are also created or updated by the import, see CreateOrUpdateTranslation.
+//
+// Existing strings and translations are treated according to ImportPolicy. Unless it is
+// config.ImportPolicyOverwrite, existing translations are never changed and those whose content
+// differs from the imported content are recorded as conflicts. With
+// config.ImportPolicyOnlyNewStrings, strings that existed before the import are skipped entirely.
+// Archived strings are restored when they are imported, whatever the policy.
+//
+// The effect on each string and translation is recorded in Diff, with each string only recorded
+// the first time that it is imported.
 func (ds *DataStore) ImportDomain(d trans.Domain) (err error) {
 
 	domId, err := ds.createOrGetDomain(d.Name())
@@ -539,83 +1728,456 @@ func (ds *DataStore) ImportDomain(d trans.Domain) (err error) {
 		return err
 	}
 
+	sourceLang, err := ds.GetSourceLanguage(d.Name())
+	if err != nil {
+		return err
+	}
+
 	for _, s := range d.Strings() {
+		key := StringKey{DomainId: domId, Name: s.Name()}
+
 		// Get the string's ID
-		stringId, ok := ds.stringCache[StringKey{DomainId: domId, Name: s.Name()}]
+		stringId, ok := ds.stringCache[key]
 		if !ok {
-			stringId, err = ds.createOrGetString(s.Name(), domId)
+			action := ChangeUnchanged
+			var archived bool
+			stringId, archived, err = ds.getString(s.Name(), domId)
+			if err == sql.ErrNoRows {
+				action = ChangeCreated
+				stringId, err = ds.createString(s.Name(), domId)
+			} else if err == nil && archived {
+				action = ChangeRestored
+				err = ds.restoreString(stringId)
+			} else if err == nil && ds.ImportPolicy == config.ImportPolicyOnlyNewStrings {
+				action = ChangeSkipped
+				ds.skippedStrings[key] = true
+			}
 			if err != nil {
 				return err
 			}
-			ds.stringCache[StringKey{DomainId: domId, Name: s.Name()}] = stringId
+			ds.stringCache[key] = stringId
+			ds.Diff.Strings = append(ds.Diff.Strings, StringChange{d.Name(), s.Name(), action})
 		}
+		skipped := ds.skippedStrings[key]
 
-		for l, t := range s.Translations() {
+		if info := trans.InfoOf(s); !info.Empty() && !skipped {
+			err = ds.setStringInfo(stringId, info)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, l := range sourceFirst(s.Translations(), sourceLang.Code) {
+			t := s.Translations()[l]
 			lang, err := ds.getLanguage(l.Code)
 			if err != nil {
 				return err
 			}
 
-			if transId, err := ds.getTranslationId(lang.Id, stringId, domId); err == nil {
-				err = ds.updateTranslation(t, transId, lang.Id, stringId, domId)
-			} else {
-				if err == sql.ErrNoRows {
-					_, err = ds.createTranslation(t, lang.Id, stringId, domId)
+			change := TranslationChange{Domain: d.Name(), StringName: s.Name(), Language: lang.Code, New: t.Content()}
+			transId, old, _, err := ds.getTranslation(lang.Id, stringId, domId)
+			switch {
+			case err == nil:
+				change.Old = old
+				switch {
+				case old == t.Content():
+					change.Action = ChangeUnchanged
+				case skipped || ds.ImportPolicy != config.ImportPolicyOverwrite:
+					change.Action = ChangeConflict
+				default:
+					change.Action = ChangeUpdated
+					err = ds.updateTranslation(t, importStatus(t), transId, lang.Id, stringId, domId)
 				}
+			case err == sql.ErrNoRows && skipped:
+				change.Action = ChangeSkipped
+				err = nil
+			case err == sql.ErrNoRows:
+				change.Action = ChangeCreated
+				transId, err = ds.createTranslation(t, importStatus(t), lang.Id, stringId, domId)
 			}
-
 			if err != nil {
 				return err
 			}
+			ds.Diff.Translations = append(ds.Diff.Translations, change)
+
+			if change.Action == ChangeSkipped || change.Action == ChangeConflict {
+				continue
+			}
+
+			if change.Action != ChangeUnchanged {
+				err = ds.trackSourceRevision(transId, lang.Id, stringId, sourceLang.Id, change.Action == ChangeUpdated)
+				if err != nil {
+					return err
+				}
+			}
+
+			if st, ok := t.(trans.StatefulTranslation); ok {
+				err = ds.setTranslationState(transId, st.State())
+				if err != nil {
+					return err
+				}
+			}
 		}
 	}
 
 	return nil
 }
 
-func (ds *DataStore) ImportDir(dir string, notify chan string) (count int, err error) {
-	files, err := filepath.Glob(filepath.Join(dir, "*.xliff"))
-	if err != nil {
-		return 0, nil
+// A file read by ImportDirs
+type importFile struct {
+	// Path of the file, relative to the directory that it was found in
+	name   string
+	domain trans.Domain
+}
+
+// ImportDirs imports all files found in the given directories that have the extension of a
+// registered format that can be imported. If recursive is true, files in their subdirectories are
+// also imported, except for those in hidden directories such as '.git'. The path of each imported
+// file, relative to the directory it was found in, is sent to notify. The names of the strings in
+// files of their domain's source language are remembered for SyncDomains. Files with translations
+// into a language that does not exist in the database, such as 'tsconfig.app.json', are added to
+// SkippedFiles instead of being imported.
+//
+// Files of their domain's source language in any of the directories are imported before any others,
+// so that translations imported along with changes to their source text are not flagged as outdated.
+func (ds *DataStore) ImportDirs(dirs []string, recursive bool, notify chan string) (count int, err error) {
+	var sourceFiles, otherFiles []importFile
+	for _, dir := range dirs {
+		files, err := findImportFiles(dir, recursive)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, file := range files {
+			d, sourceLang, err := ds.readFile(file)
+			if err != nil {
+				return 0, err
+			}
+
+			name := file
+			if rel, err := filepath.Rel(dir, file); err == nil {
+				name = rel
+			}
+
+			code, err := ds.unknownLanguage(d)
+			if err != nil {
+				return 0, err
+			}
+			if code != "" {
+				reason := fmt.Sprintf("Language '%v' does not exist in database", code)
+				ds.SkippedFiles = append(ds.SkippedFiles, SkippedFile{File: name, Reason: reason})
+				continue
+			}
+
+			if onlyInLanguage(d, sourceLang.Code) {
+				if _, ok := ds.sourceStrings[d.Name()]; !ok {
+					ds.sourceStrings[d.Name()] = make(map[string]bool)
+				}
+				for _, s := range d.Strings() {
+					ds.sourceStrings[d.Name()][s.Name()] = true
+				}
+				sourceFiles = append(sourceFiles, importFile{name: name, domain: d})
+			} else {
+				otherFiles = append(otherFiles, importFile{name: name, domain: d})
+			}
+		}
 	}
 
-	for i, file := range files {
-		xliff, err := xliff.NewFromFile(file)
+	for i, f := range append(sourceFiles, otherFiles...) {
+		err = ds.ImportDomain(f.domain)
 		if err != nil {
 			return i, err
 		}
 
-		err = ds.ImportDomain(&xliff.File.XliffDomain)
+		notify <- f.name
+	}
+
+	return len(sourceFiles) + len(otherFiles), nil
+}
+
+// Gets the code of a language that d has translations into but which does not exist in the
+// database, or an empty string if all of them exist.
+func (ds *DataStore) unknownLanguage(d trans.Domain) (code string, err error) {
+	checked := make(map[string]bool)
+	for _, s := range d.Strings() {
+		for l := range s.Translations() {
+			if checked[l.Code] {
+				continue
+			}
+			checked[l.Code] = true
+
+			var lang trans.Language
+			err = ds.conn().Get(&lang, ds.adapter.GetSingleLanguageQuery(), l.Code)
+			if err == sql.ErrNoRows {
+				return l.Code, nil
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// Finds the files in dir that can be imported, optionally including those in its subdirectories.
+// Files whose names do not give a domain name in the way their format expects, e.g. 'package.json',
+// are skipped.
+func findImportFiles(dir string, recursive bool) (files []string, err error) {
+	if !recursive {
+		for _, f := range format.Readable() {
+			for _, ext := range f.Extensions {
+				more, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+				if err != nil {
+					return nil, err
+				}
+				for _, file := range more {
+					if _, err := f.Reader.DomainName(file); err == nil {
+						files = append(files, file)
+					}
+				}
+			}
+		}
+
+		return files, nil
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return i, err
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if f, ok := format.ForExtension(filepath.Ext(path)); ok && f.Reader != nil {
+			if _, err := f.Reader.DomainName(path); err == nil {
+				files = append(files, path)
+			}
 		}
 
-		notify <- filepath.Base(file)
+		return nil
+	})
+
+	return files, err
+}
+
+// Gets the languages of a string's translations, with the source language first so that the source
+// text is imported before the translations made against it
+func sourceFirst(translations map[trans.Language]trans.Translation, sourceCode string) (langs []trans.Language) {
+	for l := range translations {
+		if l.Code == sourceCode {
+			langs = append([]trans.Language{l}, langs...)
+		} else {
+			langs = append(langs, l)
+		}
 	}
 
-	return len(files), nil
+	return langs
 }
 
-func (ds *DataStore) ExportDomain(name, dir string) (err error) {
+// Checks whether the domain has translations, all of which are in the given language, i.e. whether
+// it was read from a file of that language.
+func onlyInLanguage(d trans.Domain, code string) bool {
+	found := false
+	for _, s := range d.Strings() {
+		for l := range s.Translations() {
+			if l.Code != code {
+				return false
+			}
+			found = true
+		}
+	}
+
+	return found
+}
+
+// SyncDomains archives the strings of each domain that a source language file was imported for by
+// ImportDirs, that were not in any of the domain's imported source language files. If more than
+// threshold percent of any domain's strings would be archived, nothing is archived and an error is
+// returned, unless force is true. Archived strings are recorded in Diff.
+func (ds *DataStore) SyncDomains(threshold int, force bool) (err error) {
+	names := make([]string, 0, len(ds.sourceStrings))
+	for name := range ds.sourceStrings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	removals := make(map[string][]*String)
+	for _, name := range names {
+		d, err := ds.GetFullDomain(name)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range d.Strings() {
+			if !ds.sourceStrings[name][s.Name()] {
+				removals[name] = append(removals[name], s.(*String))
+			}
+		}
+
+		total, removed := len(d.Strings()), len(removals[name])
+		if !force && removed > 0 && removed*100 > threshold*total {
+			return errors.New(fmt.Sprintf("Syncing would archive %v of the %v strings in domain '%v', more than the threshold of %v%%. Use -force to archive them anyway", removed, total, name, threshold))
+		}
+	}
+
+	for _, name := range names {
+		for _, s := range removals[name] {
+			err = ds.archiveString(s.id)
+			if err != nil {
+				return err
+			}
+			delete(ds.stringCache, StringKey{DomainId: ds.domainCache[name], Name: s.name})
+			ds.Diff.Strings = append(ds.Diff.Strings, StringChange{name, s.name, ChangeArchived})
+		}
+	}
+
+	return nil
+}
+
+// readFile reads a file using the format registered for its extension, returning the file's domain
+// along with the domain's source language.
+func (ds *DataStore) readFile(file string) (d trans.Domain, sourceLang trans.Language, err error) {
+	f, ok := format.ForExtension(filepath.Ext(file))
+	if !ok || f.Reader == nil {
+		return nil, sourceLang, errors.New(fmt.Sprintf("No format available to import file '%v'", file))
+	}
+
+	name, err := f.Reader.DomainName(file)
+	if err != nil {
+		return nil, sourceLang, err
+	}
+
+	sourceLang, err = ds.GetSourceLanguage(name)
+	if err != nil {
+		return nil, sourceLang, err
+	}
+
+	d, err = f.Reader.Read(file, sourceLang)
+
+	return d, sourceLang, err
+}
+
+// Gets the writer of the named format.
+func getWriter(name string) (w format.Writer, err error) {
+	f, ok := format.Get(name)
+	if !ok || f.Writer == nil {
+		return nil, errors.New(fmt.Sprintf("Unrecognised export format '%v'", name))
+	}
+
+	return f.Writer, nil
+}
+
+// ExportDomain exports the named domain to the given export target. Nothing is exported if the
+// target does not include the domain.
+func (ds *DataStore) ExportDomain(name string, target config.ExportTarget) (err error) {
+	if !target.IncludesDomain(name) {
+		return nil
+	}
+
+	w, err := getWriter(target.Format)
+	if err != nil {
+		return err
+	}
+
 	d, err := ds.GetFullDomain(name)
 	if err != nil {
 		return err
 	}
 
-	l, err := ds.getLanguage("en")
+	l, err := ds.GetSourceLanguage(name)
 	if err != nil {
 		return err
 	}
 	l.Name = "" // Allows using l for lookup in result of trans.String.Translations() (since they are also missing Names)
 
-	return xliff.Export(d, l, dir)
+	if target.ApprovedOnly {
+		err = ds.useApprovedContent(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Drop the translations that the target does not include. Those in the source language are
+	// kept, as formats may use them as the source text of other translations.
+	if len(target.Languages) > 0 {
+		for _, s := range d.Strings() {
+			ts := s.Translations()
+			for tl := range ts {
+				if tl.Code != l.Code && !target.IncludesLanguage(tl.Code) {
+					delete(ts, tl)
+				}
+			}
+		}
+	}
+
+	opts := format.Options{
+		Nested:             target.JsonStyle == config.JsonStyleNested,
+		XliffVersion:       target.XliffVersion,
+		GoCatalog:          target.GoCatalog,
+		SkipSourceLanguage: !target.IncludesLanguage(l.Code),
+	}
+
+	return w.Write(d, l, target.Path, opts)
+}
+
+// Replaces the content of the domain's translations that are not approved with their content in
+// their last approved revision, dropping those that have never been approved.
+func (ds *DataStore) useApprovedContent(d trans.Domain) (err error) {
+	var rows []struct {
+		TranslationId int64  `db:"translation_id"`
+		Content       string `db:"content"`
+	}
+	err = ds.conn().Select(&rows, ds.adapter.GetDomainApprovedContentsQuery(), d.Name())
+	if err != nil {
+		return err
+	}
+
+	approved := make(map[int64]string)
+	for _, r := range rows {
+		approved[r.TranslationId] = r.Content
+	}
+
+	for _, s := range d.Strings() {
+		ts := s.Translations()
+		for l, t := range ts {
+			dt := t.(*Translation)
+			if dt.status == StatusApproved {
+				continue
+			}
+
+			if content, ok := approved[dt.id]; ok {
+				dt.content = content
+			} else {
+				delete(ts, l)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RemoveExportedFiles removes files that were previously exported to the given export target. Only
+// files belonging to the given domain and language are removed, where an empty domain or language
+// matches any.
+func (ds *DataStore) RemoveExportedFiles(target config.ExportTarget, domain, lang string) (err error) {
+	w, err := getWriter(target.Format)
+	if err != nil {
+		return err
+	}
+
+	return w.Remove(target.Path, domain, lang)
 }
 
 // SearchByStringName searches for translations by the string's name.
 func (ds *DataStore) SearchByStringName(name string) (res []SearchResult, err error) {
 	res = make([]SearchResult, 0)
 	name = fmt.Sprintf("%%%v%%", name)
-	err = ds.db.Select(&res, ds.adapter.GetSearchByStringNameQuery(), name)
+	err = ds.conn().Select(&res, ds.adapter.GetSearchByStringNameQuery(), name)
 
 	return res, err
 }
@@ -624,7 +2186,7 @@ func (ds *DataStore) SearchByStringName(name string) (res []SearchResult, err er
 func (ds *DataStore) SearchByTranslationContent(content string) (res []SearchResult, err error) {
 	res = make([]SearchResult, 0)
 	content = fmt.Sprintf("%%%v%%", content)
-	err = ds.db.Select(&res, ds.adapter.GetSearchByTranslationContentQuery(), content)
+	err = ds.conn().Select(&res, ds.adapter.GetSearchByTranslationContentQuery(), content)
 
 	return res, err
 }
@@ -633,7 +2195,117 @@ func (ds *DataStore) SearchByTranslationContent(content string) (res []SearchRes
 func (ds *DataStore) SearchByAllFields(searchTerm string) (res []SearchResult, err error) {
 	res = make([]SearchResult, 0)
 	searchTerm = fmt.Sprintf("%%%v%%", searchTerm)
-	err = ds.db.Select(&res, ds.adapter.GetSearchByAllFieldsQuery(), searchTerm, searchTerm)
+	err = ds.conn().Select(&res, ds.adapter.GetSearchByAllFieldsQuery(), searchTerm, searchTerm)
 
 	return res, err
 }
+
+// Generates the secret of a new API token
+func newTokenSecret() (secret string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Gets the hash of an API token's secret, which is stored in place of the secret itself
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateToken creates a new API token with the given name and role, returning its secret. Translator
+// tokens must be given the codes of the languages that they may change, and may be given the names
+// of the domains that they are restricted to. Other tokens must be given neither.
+// Returns ErrAlreadyExists if a token with the same name already exists.
+func (ds *DataStore) CreateToken(name, role string, languages, domains []string) (secret string, err error) {
+	if name == "" {
+		return "", errors.New("Token name must not be empty")
+	}
+	if !ValidRole(role) {
+		return "", errors.New(fmt.Sprintf("Unrecognised role '%v'. Must be one of: %v", role, strings.Join(Roles, ", ")))
+	}
+	if role == RoleTranslator && len(languages) == 0 {
+		return "", errors.New("Translator tokens must be given at least one language")
+	}
+	if role != RoleTranslator && len(languages) > 0 {
+		return "", errors.New(fmt.Sprintf("Only translator tokens can be restricted to languages, not %v tokens", role))
+	}
+	if role != RoleTranslator && len(domains) > 0 {
+		return "", errors.New(fmt.Sprintf("Only translator tokens can be restricted to domains, not %v tokens", role))
+	}
+
+	for _, code := range languages {
+		_, err = ds.getLanguage(code)
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, d := range domains {
+		_, err = ds.getDomainId(d)
+		if err == sql.ErrNoRows {
+			return "", errors.New(fmt.Sprintf("Domain '%v' does not exist in database", d))
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var id int64
+	err = ds.conn().Get(&id, ds.adapter.GetSingleTokenIdQuery(), name)
+	if err == nil {
+		return "", ErrAlreadyExists
+	} else if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	secret, err = newTokenSecret()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = ds.insert(ds.adapter.CreateTokenQuery(), name, hashTokenSecret(secret), role, strings.Join(languages, ","), strings.Join(domains, ","), time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// GetTokenList gets all API tokens, ordered by name.
+func (ds *DataStore) GetTokenList() (tokens []Token, err error) {
+	tokens = make([]Token, 0)
+	err = ds.conn().Select(&tokens, ds.adapter.GetAllTokensQuery())
+
+	return tokens, err
+}
+
+// DeleteToken deletes the named API token, so that it can no longer be used.
+// Returns sql.ErrNoRows when the given name cannot be found.
+func (ds *DataStore) DeleteToken(name string) (err error) {
+	result, err := ds.conn().Exec(ds.adapter.DeleteTokenQuery(), name)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err == nil && count == 0 {
+		err = sql.ErrNoRows
+	}
+
+	return err
+}
+
+// Authenticate gets the API token with the given secret.
+// Returns sql.ErrNoRows when no token has the secret.
+func (ds *DataStore) Authenticate(secret string) (t Token, err error) {
+	start := time.Now()
+	defer func() { ds.Stats.Log("token", "get", time.Since(start)) }()
+
+	err = ds.conn().Get(&t, ds.adapter.GetSingleTokenQuery(), hashTokenSecret(secret))
+
+	return t, err
+}
@@ -115,6 +115,73 @@ INSERT INTO language (name, code) VALUES
     ('English (DE)', 'en-de'),
     ('English (MX)', 'en-mx'),
     ('English (PE)', 'en-pe');`,
+		// 2
+		`ALTER TABLE domain ADD COLUMN source_language_id integer REFERENCES language(id) ON DELETE SET NULL ON UPDATE CASCADE;`,
+		// 3
+		`
+ALTER TABLE string ADD COLUMN extradata TEXT NOT NULL DEFAULT '';
+ALTER TABLE translation ADD COLUMN state TEXT NOT NULL DEFAULT '';
+ALTER TABLE translation ADD COLUMN approved BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE note (
+    id SERIAL PRIMARY KEY,
+    string_id integer REFERENCES string(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    content TEXT
+);
+CREATE INDEX note_string_id_idx ON note (string_id);
+CREATE TABLE reference_file (
+    id SERIAL PRIMARY KEY,
+    string_id integer REFERENCES string(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    file TEXT,
+    line integer NOT NULL DEFAULT 0
+);
+CREATE INDEX reference_file_string_id_idx ON reference_file (string_id);
+`,
+		// 4
+		`
+ALTER TABLE string ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX string_archived_at_idx ON string (archived_at);
+`,
+		// 5
+		`
+CREATE TABLE translation_revision (
+    id SERIAL PRIMARY KEY,
+    string_id integer REFERENCES string(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    language_id integer REFERENCES language(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    content TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE,
+    author TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX translation_revision_string_id_language_id_idx ON translation_revision (string_id, language_id);
+INSERT INTO translation_revision (string_id, language_id, content, action, created_at, source)
+    SELECT string_id, language_id, COALESCE(content, ''), 'created', NOW(), 'migration' FROM translation;
+`,
+		// 6
+		`
+CREATE TABLE api_token (
+    id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    role TEXT NOT NULL,
+    languages TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE
+);
+`,
+		// 7
+		`ALTER TABLE api_token ADD COLUMN domains TEXT NOT NULL DEFAULT '';`,
+		// 8
+		`
+ALTER TABLE translation ADD COLUMN status TEXT NOT NULL DEFAULT 'new';
+UPDATE translation SET status = 'approved';
+ALTER TABLE translation_revision ADD COLUMN status TEXT NOT NULL DEFAULT 'new';
+UPDATE translation_revision SET status = 'approved';
+`,
+		// 9
+		`
+ALTER TABLE translation ADD COLUMN source_revision_id integer REFERENCES translation_revision(id) ON DELETE SET NULL ON UPDATE CASCADE;
+ALTER TABLE translation ADD COLUMN outdated BOOLEAN NOT NULL DEFAULT FALSE;
+`,
 	}
 }
 
@@ -128,9 +195,36 @@ DROP TABLE IF EXISTS language;
 DROP TABLE IF EXISTS domain;
 `,
 		// 2
-		`DELETE FROM language WHERE code = 'nl';`,
+		`ALTER TABLE domain DROP COLUMN IF EXISTS source_language_id;`,
 		// 3
-		`UPDATE language SET name = 'Duth' WHERE name = 'Dutch' AND code = 'nl';`,
+		`
+DROP TABLE IF EXISTS reference_file;
+DROP TABLE IF EXISTS note;
+ALTER TABLE translation DROP COLUMN IF EXISTS approved;
+ALTER TABLE translation DROP COLUMN IF EXISTS state;
+ALTER TABLE string DROP COLUMN IF EXISTS extradata;
+`,
+		// 4
+		`
+DROP INDEX string_archived_at_idx;
+ALTER TABLE string DROP COLUMN archived_at;
+`,
+		// 5
+		`DROP TABLE translation_revision;`,
+		// 6
+		`DROP TABLE api_token;`,
+		// 7
+		`ALTER TABLE api_token DROP COLUMN domains;`,
+		// 8
+		`
+ALTER TABLE translation_revision DROP COLUMN IF EXISTS status;
+ALTER TABLE translation DROP COLUMN IF EXISTS status;
+`,
+		// 9
+		`
+ALTER TABLE translation DROP COLUMN IF EXISTS outdated;
+ALTER TABLE translation DROP COLUMN IF EXISTS source_revision_id;
+`,
 	}
 }
 
@@ -201,6 +295,10 @@ func (a PostgresAdapter) SupportsLastInsertId() bool {
 	return false
 }
 
+func (a PostgresAdapter) ArchiveStringQuery() string {
+	return `UPDATE string SET archived_at=$1 WHERE id=$2;`
+}
+
 func (a PostgresAdapter) CreateDomainQuery() string {
 	return `INSERT INTO domain (name) VALUES ($1) RETURNING id;`
 }
@@ -209,16 +307,48 @@ func (a PostgresAdapter) CreateLanguageQuery() string {
 	return `INSERT INTO language (code, name) VALUES ($1, $2) RETURNING id;`
 }
 
+func (a PostgresAdapter) CreateNoteQuery() string {
+	return `INSERT INTO note (string_id, content) VALUES ($1, $2);`
+}
+
+func (a PostgresAdapter) CreateReferenceFileQuery() string {
+	return `INSERT INTO reference_file (string_id, file, line) VALUES ($1, $2, $3);`
+}
+
+func (a PostgresAdapter) CreateRevisionQuery() string {
+	return `INSERT INTO translation_revision (string_id, language_id, content, status, action, created_at, author, source) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;`
+}
+
 func (a PostgresAdapter) CreateStringQuery() string {
 	return `INSERT INTO string (name, domain_id) VALUES ($1, $2) RETURNING id;`
 }
 
+func (a PostgresAdapter) CreateTokenQuery() string {
+	return `INSERT INTO api_token (name, token_hash, role, languages, domains, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;`
+}
+
 func (a PostgresAdapter) CreateTranslationQuery() string {
-	return `INSERT INTO translation (language_id, content, string_id) VALUES ($1, $2, $3) RETURNING id;`
+	return `INSERT INTO translation (language_id, content, string_id, status) VALUES ($1, $2, $3, $4) RETURNING id;`
+}
+
+func (a PostgresAdapter) DeleteDomainQuery() string {
+	return `DELETE FROM domain WHERE id = $1;`
 }
 
-func (a PostgresAdapter) DeleteStringQuery() string {
-	return `DELETE FROM string WHERE id = $1;`
+func (a PostgresAdapter) DeleteLanguageQuery() string {
+	return `DELETE FROM language WHERE id = $1;`
+}
+
+func (a PostgresAdapter) DeleteStringNotesQuery() string {
+	return `DELETE FROM note WHERE string_id = $1;`
+}
+
+func (a PostgresAdapter) DeleteStringReferenceFilesQuery() string {
+	return `DELETE FROM reference_file WHERE string_id = $1;`
+}
+
+func (a PostgresAdapter) DeleteTokenQuery() string {
+	return `DELETE FROM api_token WHERE name = $1;`
 }
 
 func (a PostgresAdapter) DeleteTranslationQuery() string {
@@ -233,6 +363,110 @@ func (a PostgresAdapter) GetAllLanguagesQuery() string {
 	return `SELECT id, code, name FROM language ORDER BY code;`
 }
 
+func (a PostgresAdapter) GetAllTokensQuery() string {
+	return `SELECT id, name, role, languages, domains, created_at FROM api_token ORDER BY name;`
+}
+
+func (a PostgresAdapter) GetArchivedStringsQuery() string {
+	return `
+SELECT
+    s.name AS string_name,
+    s.archived_at,
+    l.code AS language_code,
+    t.content
+FROM string s
+INNER JOIN domain d ON s.domain_id = d.id
+LEFT JOIN translation t ON s.id = t.string_id
+LEFT JOIN language l ON t.language_id = l.id
+WHERE d.name = $1 AND s.archived_at IS NOT NULL
+ORDER BY s.name;
+`
+}
+
+func (a PostgresAdapter) GetDomainApprovedContentsQuery() string {
+	return `
+SELECT
+    t.id AS translation_id,
+    r.content
+FROM string s
+INNER JOIN domain d ON d.id = s.domain_id
+INNER JOIN translation t ON t.string_id = s.id
+INNER JOIN translation_revision r ON r.id = (
+    SELECT MAX(r2.id) FROM translation_revision r2
+    WHERE r2.string_id = t.string_id AND r2.language_id = t.language_id AND r2.status = 'approved' AND r2.action <> 'deleted'
+)
+WHERE d.name = $1 AND s.archived_at IS NULL AND t.status <> 'approved';`
+}
+
+func (a PostgresAdapter) GetDomainNotesQuery() string {
+	return `
+SELECT n.string_id, n.content
+FROM note n
+INNER JOIN string s ON n.string_id = s.id
+INNER JOIN domain d ON s.domain_id = d.id
+WHERE d.name = $1
+ORDER BY n.id;
+`
+}
+
+func (a PostgresAdapter) GetDomainReferenceFilesQuery() string {
+	return `
+SELECT r.string_id, r.file, r.line
+FROM reference_file r
+INNER JOIN string s ON r.string_id = s.id
+INNER JOIN domain d ON s.domain_id = d.id
+WHERE d.name = $1
+ORDER BY r.id;
+`
+}
+
+func (a PostgresAdapter) GetLanguageStatsQuery() string {
+	return `
+SELECT
+    d.name AS domain_name,
+    COUNT(s.id) AS string_count,
+    COUNT(t.id) AS translated_count
+FROM domain d
+LEFT JOIN string s ON d.id = s.domain_id AND s.archived_at IS NULL
+LEFT JOIN translation t ON s.id = t.string_id AND t.language_id = $1
+GROUP BY d.id, d.name
+ORDER BY d.name;`
+}
+
+func (a PostgresAdapter) GetLanguageTranslationCountQuery() string {
+	return `SELECT COUNT(*) FROM translation WHERE language_id = $1;`
+}
+
+func (a PostgresAdapter) GetMissingStringsQuery() string {
+	return `
+SELECT s.name
+FROM string s
+INNER JOIN domain d ON s.domain_id = d.id
+LEFT JOIN translation t ON s.id = t.string_id AND t.language_id = $1
+WHERE d.name = $2 AND s.archived_at IS NULL AND t.id IS NULL
+ORDER BY s.name;`
+}
+
+func (a PostgresAdapter) GetOutdatedTranslationsQuery() string {
+	return `
+SELECT
+    d.name AS domain_name,
+    s.name AS string_name,
+    l.code AS language_code,
+    t.content,
+    COALESCE(st.content, '') AS source_content,
+    COALESCE(sr.content, '') AS previous_source_content
+FROM translation t
+INNER JOIN language l ON t.language_id = l.id
+INNER JOIN string s ON t.string_id = s.id AND s.archived_at IS NULL
+INNER JOIN domain d ON s.domain_id = d.id
+LEFT JOIN translation st ON s.id = st.string_id
+    AND st.language_id = COALESCE(d.source_language_id, (SELECT id FROM language WHERE code = $1))
+LEFT JOIN translation_revision sr ON t.source_revision_id = sr.id
+WHERE t.outdated AND (l.code = $2 OR $3 = '')
+ORDER BY d.name, s.name, l.code;`
+}
+
 func (a PostgresAdapter) GetSearchByStringNameQuery() string {
 	return `
 SELECT
@@ -245,7 +479,7 @@ SELECT
     t.id AS translation_id,
     t.content AS translation_content
 FROM translation t
-INNER JOIN string s ON s.id = t.string_id
+INNER JOIN string s ON s.id = t.string_id AND s.archived_at IS NULL
 INNER JOIN language l ON t.language_id = l.id
 INNER JOIN domain d ON s.domain_id = d.id
 WHERE s.name LIKE $1
@@ -264,7 +498,7 @@ SELECT
     t.id AS translation_id,
     t.content AS translation_content
 FROM translation t
-INNER JOIN string s ON s.id = t.string_id
+INNER JOIN string s ON s.id = t.string_id AND s.archived_at IS NULL
 INNER JOIN language l ON t.language_id = l.id
 INNER JOIN domain d ON s.domain_id = d.id
 WHERE t.content LIKE $1
@@ -283,7 +517,7 @@ SELECT
     t.id AS translation_id,
     t.content AS translation_content
 FROM translation t
-INNER JOIN string s ON s.id = t.string_id
+INNER JOIN string s ON s.id = t.string_id AND s.archived_at IS NULL
 INNER JOIN language l ON t.language_id = l.id
 INNER JOIN domain d ON s.domain_id = d.id
 WHERE s.name LIKE $1 OR t.content LIKE $2
@@ -296,12 +530,17 @@ SELECT
     d.id AS domain_id,
     s.id AS string_id,
     s.name AS string_name,
+    s.extradata,
     t.language_id AS language_id,
     l.code AS language_code,
     t.id AS translation_id,
-    t.content
+    t.content,
+    t.state,
+    t.approved,
+    t.status,
+    t.outdated
 FROM domain d
-LEFT JOIN string s ON d.id = s.domain_id
+LEFT JOIN string s ON d.id = s.domain_id AND s.archived_at IS NULL
 LEFT JOIN translation t ON s.id = t.string_id 
 LEFT JOIN language l ON t.language_id = l.id 
 WHERE d.name = $1
@@ -312,20 +551,100 @@ func (a PostgresAdapter) GetSingleDomainIdQuery() string {
 	return `SELECT id FROM domain WHERE name=$1;`
 }
 
+func (a PostgresAdapter) GetDomainSourceLanguageQuery() string {
+	return `SELECT l.id, l.name, l.code FROM domain d INNER JOIN language l ON d.source_language_id = l.id WHERE d.name=$1;`
+}
+
 func (a PostgresAdapter) GetSingleLanguageQuery() string {
 	return `SELECT id, name, code FROM language WHERE code=$1;`
 }
 
-func (a PostgresAdapter) GetSingleStringIdQuery() string {
-	return `SELECT id FROM string WHERE name = $1 AND domain_id = $2;`
+func (a PostgresAdapter) GetSingleRevisionQuery() string {
+	return `SELECT id, content, status, action, created_at, author, source FROM translation_revision WHERE id = $1 AND string_id = $2 AND language_id = $3;`
+}
+
+func (a PostgresAdapter) GetSingleStringQuery() string {
+	return `SELECT id, archived_at IS NOT NULL FROM string WHERE name = $1 AND domain_id = $2;`
 }
 
-func (a PostgresAdapter) GetSingleTranslationIdQuery() string {
-	return `SELECT translation.id FROM string INNER JOIN translation ON string.id = translation.string_id WHERE string.id=$1 AND language_id=$2 AND domain_id=$3;`
+func (a PostgresAdapter) GetSingleTokenQuery() string {
+	return `SELECT id, name, role, languages, domains, created_at FROM api_token WHERE token_hash = $1;`
+}
+
+func (a PostgresAdapter) GetSingleTokenIdQuery() string {
+	return `SELECT id FROM api_token WHERE name = $1;`
+}
+
+func (a PostgresAdapter) GetSingleTranslationQuery() string {
+	return `SELECT translation.id, translation.content, translation.status FROM string INNER JOIN translation ON string.id = translation.string_id WHERE string.id=$1 AND language_id=$2 AND domain_id=$3;`
+}
+
+func (a PostgresAdapter) GetSourceRevisionIdQuery() string {
+	return `SELECT MAX(id) FROM translation_revision WHERE string_id = $1 AND language_id = $2 AND action IN ('created', 'updated');`
+}
+
+func (a PostgresAdapter) GetTranslationRevisionsQuery() string {
+	return `SELECT id, content, status, action, created_at, author, source FROM translation_revision WHERE string_id = $1 AND language_id = $2 ORDER BY id DESC;`
+}
+
+func (a PostgresAdapter) GetTranslationStatsQuery() string {
+	return `
+SELECT
+    d.name AS domain_name,
+    l.code AS language_code,
+    COUNT(s.id) AS string_count,
+    COUNT(t.id) AS translated_count,
+    COUNT(CASE WHEN t.outdated THEN 1 END) AS outdated_count
+FROM domain d
+CROSS JOIN language l
+LEFT JOIN string s ON d.id = s.domain_id AND s.archived_at IS NULL
+LEFT JOIN translation t ON s.id = t.string_id AND t.language_id = l.id
+GROUP BY d.id, d.name, l.id, l.code
+ORDER BY d.name, l.code;`
+}
+
+func (a PostgresAdapter) MarkTranslationsOutdatedQuery() string {
+	return `UPDATE translation SET outdated=$1 WHERE string_id=$2 AND language_id<>$3;`
+}
+
+func (a PostgresAdapter) PurgeArchivedStringsQuery() string {
+	return `DELETE FROM string WHERE archived_at IS NOT NULL AND archived_at < $1;`
+}
+
+func (a PostgresAdapter) RenameDomainQuery() string {
+	return `UPDATE domain SET name=$1 WHERE id=$2;`
+}
+
+func (a PostgresAdapter) RestoreStringQuery() string {
+	return `UPDATE string SET archived_at=NULL WHERE id=$1;`
+}
+
+func (a PostgresAdapter) SetDomainSourceLanguageQuery() string {
+	return `UPDATE domain SET source_language_id=$1 WHERE id=$2;`
+}
+
+func (a PostgresAdapter) UpdateLanguageQuery() string {
+	return `UPDATE language SET code=$1, name=$2 WHERE id=$3;`
+}
+
+func (a PostgresAdapter) UpdateStringExtraDataQuery() string {
+	return `UPDATE string SET extradata=$1 WHERE id=$2;`
 }
 
 func (a PostgresAdapter) UpdateTranslationQuery() string {
-	return `UPDATE translation SET language_id=$1, content=$2, string_id=$3 WHERE id=$4;`
+	return `UPDATE translation SET language_id=$1, content=$2, string_id=$3, status=$4 WHERE id=$5;`
+}
+
+func (a PostgresAdapter) UpdateTranslationSourceRevisionQuery() string {
+	return `UPDATE translation SET source_revision_id=$1, outdated=$2 WHERE id=$3;`
+}
+
+func (a PostgresAdapter) UpdateTranslationStateQuery() string {
+	return `UPDATE translation SET state=$1, approved=$2 WHERE id=$3;`
+}
+
+func (a PostgresAdapter) UpdateTranslationStatusQuery() string {
+	return `UPDATE translation SET status=$1 WHERE id=$2;`
 }
 
 func (a PostgresAdapter) version(db *sqlx.DB) (version int64, err error) {
@@ -106,6 +106,93 @@ INSERT INTO language (name, code) VALUES
 		`INSERT INTO language (code, name) VALUES ("nl", "Dutch")`,
 		// 3
 		`CREATE INDEX "translation_content" ON "translation"("content");`,
+		// 4
+		`ALTER TABLE "domain" ADD COLUMN "source_language_id" INTEGER REFERENCES "language"("id") ON UPDATE CASCADE ON DELETE SET NULL;`,
+		// 5
+		`
+DELETE FROM "translation" WHERE EXISTS (
+    SELECT 1 FROM "translation" t2
+    INNER JOIN "language" l1 ON l1."id" = "translation"."language_id"
+    INNER JOIN "language" l2 ON l2."id" = t2."language_id"
+    WHERE t2."string_id" = "translation"."string_id" AND l2."code" = l1."code" AND t2."id" > "translation"."id"
+);
+UPDATE "translation" SET "language_id" = (
+    SELECT MIN(l2."id") FROM "language" l1 INNER JOIN "language" l2 ON l1."code" = l2."code" WHERE l1."id" = "translation"."language_id"
+);
+UPDATE "domain" SET "source_language_id" = (
+    SELECT MIN(l2."id") FROM "language" l1 INNER JOIN "language" l2 ON l1."code" = l2."code" WHERE l1."id" = "domain"."source_language_id"
+) WHERE "source_language_id" IS NOT NULL;
+DELETE FROM "language" WHERE "id" NOT IN (SELECT MIN("id") FROM "language" GROUP BY "code");
+DROP INDEX "code";
+CREATE UNIQUE INDEX "code" ON "language" ("code");
+DROP INDEX "string_id_language_id";
+CREATE UNIQUE INDEX "string_id_language_id" ON "translation" ("language_id","string_id");
+`,
+		// 6
+		`
+ALTER TABLE "string" ADD COLUMN "extradata" TEXT NOT NULL DEFAULT '';
+ALTER TABLE "translation" ADD COLUMN "state" TEXT NOT NULL DEFAULT '';
+ALTER TABLE "translation" ADD COLUMN "approved" BOOLEAN NOT NULL DEFAULT 0;
+CREATE TABLE "note" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "string_id" INTEGER REFERENCES "string"("id") ON UPDATE CASCADE ON DELETE CASCADE,
+    "content" TEXT
+);
+CREATE INDEX "note_string_id" ON "note" ("string_id");
+CREATE TABLE "reference_file" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "string_id" INTEGER REFERENCES "string"("id") ON UPDATE CASCADE ON DELETE CASCADE,
+    "file" TEXT,
+    "line" INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX "reference_file_string_id" ON "reference_file" ("string_id");
+`,
+		// 7
+		`
+ALTER TABLE "string" ADD COLUMN "archived_at" DATETIME;
+CREATE INDEX "string_archived_at" ON "string" ("archived_at");
+`,
+		// 8
+		`
+CREATE TABLE "translation_revision" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "string_id" INTEGER REFERENCES "string"("id") ON UPDATE CASCADE ON DELETE CASCADE,
+    "language_id" INTEGER REFERENCES "language"("id") ON UPDATE CASCADE ON DELETE CASCADE,
+    "content" TEXT NOT NULL DEFAULT '',
+    "action" TEXT NOT NULL DEFAULT '',
+    "created_at" DATETIME,
+    "author" TEXT NOT NULL DEFAULT '',
+    "source" TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX "translation_revision_string_id_language_id" ON "translation_revision" ("string_id","language_id");
+INSERT INTO "translation_revision" ("string_id", "language_id", "content", "action", "created_at", "source")
+    SELECT "string_id", "language_id", COALESCE("content", ''), 'created', CURRENT_TIMESTAMP, 'migration' FROM "translation";
+`,
+		// 9
+		`
+CREATE TABLE "api_token" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "name" TEXT UNIQUE NOT NULL,
+    "token_hash" TEXT UNIQUE NOT NULL,
+    "role" TEXT NOT NULL,
+    "languages" TEXT NOT NULL DEFAULT '',
+    "created_at" DATETIME
+);
+`,
+		// 10
+		`ALTER TABLE "api_token" ADD COLUMN "domains" TEXT NOT NULL DEFAULT '';`,
+		// 11
+		`
+ALTER TABLE "translation" ADD COLUMN "status" TEXT NOT NULL DEFAULT 'new';
+UPDATE "translation" SET "status" = 'approved';
+ALTER TABLE "translation_revision" ADD COLUMN "status" TEXT NOT NULL DEFAULT 'new';
+UPDATE "translation_revision" SET "status" = 'approved';
+`,
+		// 12
+		`
+ALTER TABLE "translation" ADD COLUMN "source_revision_id" INTEGER REFERENCES "translation_revision"("id") ON UPDATE CASCADE ON DELETE SET NULL;
+ALTER TABLE "translation" ADD COLUMN "outdated" BOOLEAN NOT NULL DEFAULT 0;
+`,
 	}
 }
 
@@ -122,6 +209,44 @@ DROP TABLE domain;
 		`DELETE FROM language WHERE code = "nl"`,
 		// 3
 		`DROP INDEX "translation_content";`,
+		// 4
+		`ALTER TABLE "domain" DROP COLUMN "source_language_id";`,
+		// 5
+		`
+DROP INDEX "string_id_language_id";
+CREATE INDEX "string_id_language_id" ON "translation" ("language_id","string_id");
+DROP INDEX "code";
+CREATE INDEX "code" ON "language" ("code");
+`,
+		// 6
+		`
+DROP TABLE "reference_file";
+DROP TABLE "note";
+ALTER TABLE "translation" DROP COLUMN "approved";
+ALTER TABLE "translation" DROP COLUMN "state";
+ALTER TABLE "string" DROP COLUMN "extradata";
+`,
+		// 7
+		`
+DROP INDEX "string_archived_at";
+ALTER TABLE "string" DROP COLUMN "archived_at";
+`,
+		// 8
+		`DROP TABLE "translation_revision";`,
+		// 9
+		`DROP TABLE "api_token";`,
+		// 10
+		`ALTER TABLE "api_token" DROP COLUMN "domains";`,
+		// 11
+		`
+ALTER TABLE "translation_revision" DROP COLUMN "status";
+ALTER TABLE "translation" DROP COLUMN "status";
+`,
+		// 12
+		`
+ALTER TABLE "translation" DROP COLUMN "outdated";
+ALTER TABLE "translation" DROP COLUMN "source_revision_id";
+`,
 	}
 }
 
@@ -192,6 +317,10 @@ func (s Sqlite3Adapter) SupportsLastInsertId() bool {
 	return true
 }
 
+func (s Sqlite3Adapter) ArchiveStringQuery() string {
+	return "UPDATE string SET archived_at=? WHERE id=?"
+}
+
 func (s Sqlite3Adapter) CreateDomainQuery() string {
 	return "INSERT INTO domain (name) VALUES (?)"
 }
@@ -200,16 +329,48 @@ func (s Sqlite3Adapter) CreateLanguageQuery() string {
 	return "INSERT INTO language (code, name) VALUES (?, ?)"
 }
 
+func (s Sqlite3Adapter) CreateNoteQuery() string {
+	return "INSERT INTO note (string_id, content) VALUES (?, ?)"
+}
+
+func (s Sqlite3Adapter) CreateReferenceFileQuery() string {
+	return "INSERT INTO reference_file (string_id, file, line) VALUES (?, ?, ?)"
+}
+
+func (s Sqlite3Adapter) CreateRevisionQuery() string {
+	return "INSERT INTO translation_revision (string_id, language_id, content, status, action, created_at, author, source) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+}
+
 func (s Sqlite3Adapter) CreateStringQuery() string {
 	return "INSERT INTO string (name, domain_id) VALUES (?, ?)"
 }
 
+func (s Sqlite3Adapter) CreateTokenQuery() string {
+	return "INSERT INTO api_token (name, token_hash, role, languages, domains, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+}
+
 func (s Sqlite3Adapter) CreateTranslationQuery() string {
-	return "INSERT INTO translation (language_id, content, string_id) VALUES (?, ?, ?)"
+	return "INSERT INTO translation (language_id, content, string_id, status) VALUES (?, ?, ?, ?)"
 }
 
-func (s Sqlite3Adapter) DeleteStringQuery() string {
-	return "DELETE FROM string WHERE id = ?"
+func (s Sqlite3Adapter) DeleteDomainQuery() string {
+	return "DELETE FROM domain WHERE id = ?"
+}
+
+func (s Sqlite3Adapter) DeleteLanguageQuery() string {
+	return "DELETE FROM language WHERE id = ?"
+}
+
+func (s Sqlite3Adapter) DeleteStringNotesQuery() string {
+	return "DELETE FROM note WHERE string_id = ?"
+}
+
+func (s Sqlite3Adapter) DeleteStringReferenceFilesQuery() string {
+	return "DELETE FROM reference_file WHERE string_id = ?"
+}
+
+func (s Sqlite3Adapter) DeleteTokenQuery() string {
+	return "DELETE FROM api_token WHERE name=?"
 }
 
 func (s Sqlite3Adapter) DeleteTranslationQuery() string {
@@ -224,6 +385,111 @@ func (s Sqlite3Adapter) GetAllLanguagesQuery() string {
 	return "SELECT id, code, name FROM language ORDER BY code"
 }
 
+func (s Sqlite3Adapter) GetAllTokensQuery() string {
+	return "SELECT id, name, role, languages, domains, created_at FROM api_token ORDER BY name"
+}
+
+func (s Sqlite3Adapter) GetArchivedStringsQuery() string {
+	return `
+SELECT
+    s.name AS string_name,
+    s.archived_at,
+    l.code AS language_code,
+    t.content
+FROM string s
+INNER JOIN domain d ON s.domain_id = d.id
+LEFT JOIN translation t ON s.id = t.string_id
+LEFT JOIN language l ON t.language_id = l.id
+WHERE d.name = ? AND s.archived_at IS NOT NULL
+ORDER BY s.name;
+`
+}
+
+func (s Sqlite3Adapter) GetDomainApprovedContentsQuery() string {
+	return `
+SELECT
+    t.id AS translation_id,
+    r.content
+FROM string s
+INNER JOIN domain d ON d.id = s.domain_id
+INNER JOIN translation t ON t.string_id = s.id
+INNER JOIN translation_revision r ON r.id = (
+    SELECT MAX(r2.id) FROM translation_revision r2
+    WHERE r2.string_id = t.string_id AND r2.language_id = t.language_id AND r2.status = 'approved' AND r2.action <> 'deleted'
+)
+WHERE d.name = ? AND s.archived_at IS NULL AND t.status <> 'approved';`
+}
+
+func (s Sqlite3Adapter) GetDomainNotesQuery() string {
+	return `
+SELECT n.string_id, n.content
+FROM note n
+INNER JOIN string s ON n.string_id = s.id
+INNER JOIN domain d ON s.domain_id = d.id
+WHERE d.name = ?
+ORDER BY n.id;
+`
+}
+
+func (s Sqlite3Adapter) GetDomainReferenceFilesQuery() string {
+	return `
+SELECT r.string_id, r.file, r.line
+FROM reference_file r
+INNER JOIN string s ON r.string_id = s.id
+INNER JOIN domain d ON s.domain_id = d.id
+WHERE d.name = ?
+ORDER BY r.id;
+`
+}
+
+func (s Sqlite3Adapter) GetLanguageStatsQuery() string {
+	return `
+SELECT
+    d.name AS domain_name,
+    COUNT(s.id) AS string_count,
+    COUNT(t.id) AS translated_count
+FROM domain d
+LEFT JOIN string s ON d.id = s.domain_id AND s.archived_at IS NULL
+LEFT JOIN translation t ON s.id = t.string_id AND t.language_id = ?
+GROUP BY d.id, d.name
+ORDER BY d.name;
+`
+}
+
+func (s Sqlite3Adapter) GetLanguageTranslationCountQuery() string {
+	return "SELECT COUNT(*) FROM translation WHERE language_id = ?"
+}
+
+func (s Sqlite3Adapter) GetMissingStringsQuery() string {
+	return `
+SELECT s.name
+FROM string s
+INNER JOIN domain d ON s.domain_id = d.id
+LEFT JOIN translation t ON s.id = t.string_id AND t.language_id = ?
+WHERE d.name = ? AND s.archived_at IS NULL AND t.id IS NULL
+ORDER BY s.name;`
+}
+
+func (s Sqlite3Adapter) GetOutdatedTranslationsQuery() string {
+	return `
+SELECT
+    d.name AS domain_name,
+    s.name AS string_name,
+    l.code AS language_code,
+    t.content,
+    COALESCE(st.content, '') AS source_content,
+    COALESCE(sr.content, '') AS previous_source_content
+FROM translation t
+INNER JOIN language l ON t.language_id = l.id
+INNER JOIN string s ON t.string_id = s.id AND s.archived_at IS NULL
+INNER JOIN domain d ON s.domain_id = d.id
+LEFT JOIN translation st ON s.id = st.string_id
+    AND st.language_id = COALESCE(d.source_language_id, (SELECT id FROM language WHERE code = ?))
+LEFT JOIN translation_revision sr ON t.source_revision_id = sr.id
+WHERE t.outdated AND (l.code = ? OR ? = '')
+ORDER BY d.name, s.name, l.code;`
+}
+
 func (s Sqlite3Adapter) GetSearchByStringNameQuery() string {
 	return `
 SELECT
@@ -236,7 +502,7 @@ SELECT
     t.id AS translation_id,
     t.content AS translation_content
 FROM translation t
-INNER JOIN string s ON s.id = t.string_id
+INNER JOIN string s ON s.id = t.string_id AND s.archived_at IS NULL
 INNER JOIN language l ON t.language_id = l.id
 INNER JOIN domain d ON s.domain_id = d.id
 WHERE s.name LIKE ?
@@ -256,7 +522,7 @@ SELECT
     t.id AS translation_id,
     t.content AS translation_content
 FROM translation t
-INNER JOIN string s ON s.id = t.string_id
+INNER JOIN string s ON s.id = t.string_id AND s.archived_at IS NULL
 INNER JOIN language l ON t.language_id = l.id
 INNER JOIN domain d ON s.domain_id = d.id
 WHERE t.content LIKE ?
@@ -276,7 +542,7 @@ SELECT
     t.id AS translation_id,
     t.content AS translation_content
 FROM translation t
-INNER JOIN string s ON s.id = t.string_id
+INNER JOIN string s ON s.id = t.string_id AND s.archived_at IS NULL
 INNER JOIN language l ON t.language_id = l.id
 INNER JOIN domain d ON s.domain_id = d.id
 WHERE s.name LIKE ? OR t.content LIKE ?
@@ -290,12 +556,17 @@ SELECT
     d.id AS domain_id,
     s.id AS string_id,
     s.name AS string_name,
+    s.extradata,
     t.language_id AS language_id,
     l.code AS language_code,
     t.id AS translation_id,
-    t.content
+    t.content,
+    t.state,
+    t.approved,
+    t.status,
+    t.outdated
 FROM domain d
-LEFT JOIN string s ON d.id = s.domain_id
+LEFT JOIN string s ON d.id = s.domain_id AND s.archived_at IS NULL
 LEFT JOIN translation t ON s.id = t.string_id 
 LEFT JOIN language l ON t.language_id = l.id 
 WHERE d.name = ?
@@ -306,20 +577,100 @@ func (s Sqlite3Adapter) GetSingleDomainIdQuery() string {
 	return "SELECT id FROM domain WHERE name=?"
 }
 
+func (s Sqlite3Adapter) GetDomainSourceLanguageQuery() string {
+	return "SELECT l.id, l.name, l.code FROM domain d INNER JOIN language l ON d.source_language_id = l.id WHERE d.name=?"
+}
+
 func (s Sqlite3Adapter) GetSingleLanguageQuery() string {
 	return "SELECT id, name, code FROM language WHERE code=?"
 }
 
-func (s Sqlite3Adapter) GetSingleStringIdQuery() string {
-	return "SELECT id FROM string WHERE name = ? AND domain_id = ?"
+func (s Sqlite3Adapter) GetSingleRevisionQuery() string {
+	return "SELECT id, content, status, action, created_at, author, source FROM translation_revision WHERE id = ? AND string_id = ? AND language_id = ?"
 }
 
-func (s Sqlite3Adapter) GetSingleTranslationIdQuery() string {
-	return "SELECT translation.id FROM string INNER JOIN translation ON string.id = translation.string_id WHERE string.id=? AND language_id=? AND domain_id=?"
+func (s Sqlite3Adapter) GetSingleStringQuery() string {
+	return "SELECT id, archived_at IS NOT NULL FROM string WHERE name = ? AND domain_id = ?"
+}
+
+func (s Sqlite3Adapter) GetSingleTokenQuery() string {
+	return "SELECT id, name, role, languages, domains, created_at FROM api_token WHERE token_hash=?"
+}
+
+func (s Sqlite3Adapter) GetSingleTokenIdQuery() string {
+	return "SELECT id FROM api_token WHERE name=?"
+}
+
+func (s Sqlite3Adapter) GetSingleTranslationQuery() string {
+	return "SELECT translation.id, translation.content, translation.status FROM string INNER JOIN translation ON string.id = translation.string_id WHERE string.id=? AND language_id=? AND domain_id=?"
+}
+
+func (s Sqlite3Adapter) GetSourceRevisionIdQuery() string {
+	return "SELECT MAX(id) FROM translation_revision WHERE string_id = ? AND language_id = ? AND action IN ('created', 'updated')"
+}
+
+func (s Sqlite3Adapter) GetTranslationRevisionsQuery() string {
+	return "SELECT id, content, status, action, created_at, author, source FROM translation_revision WHERE string_id = ? AND language_id = ? ORDER BY id DESC"
+}
+
+func (s Sqlite3Adapter) GetTranslationStatsQuery() string {
+	return `
+SELECT
+    d.name AS domain_name,
+    l.code AS language_code,
+    COUNT(s.id) AS string_count,
+    COUNT(t.id) AS translated_count,
+    COUNT(CASE WHEN t.outdated THEN 1 END) AS outdated_count
+FROM domain d
+CROSS JOIN language l
+LEFT JOIN string s ON d.id = s.domain_id AND s.archived_at IS NULL
+LEFT JOIN translation t ON s.id = t.string_id AND t.language_id = l.id
+GROUP BY d.id, d.name, l.id, l.code
+ORDER BY d.name, l.code;`
+}
+
+func (s Sqlite3Adapter) MarkTranslationsOutdatedQuery() string {
+	return "UPDATE translation SET outdated=? WHERE string_id=? AND language_id<>?"
+}
+
+func (s Sqlite3Adapter) PurgeArchivedStringsQuery() string {
+	return "DELETE FROM string WHERE archived_at IS NOT NULL AND archived_at < ?"
+}
+
+func (s Sqlite3Adapter) RenameDomainQuery() string {
+	return "UPDATE domain SET name=? WHERE id=?"
+}
+
+func (s Sqlite3Adapter) RestoreStringQuery() string {
+	return "UPDATE string SET archived_at=NULL WHERE id=?"
+}
+
+func (s Sqlite3Adapter) SetDomainSourceLanguageQuery() string {
+	return "UPDATE domain SET source_language_id=? WHERE id=?"
+}
+
+func (s Sqlite3Adapter) UpdateLanguageQuery() string {
+	return "UPDATE language SET code=?, name=? WHERE id=?"
+}
+
+func (s Sqlite3Adapter) UpdateStringExtraDataQuery() string {
+	return "UPDATE string SET extradata=? WHERE id=?"
 }
 
 func (s Sqlite3Adapter) UpdateTranslationQuery() string {
-	return "UPDATE translation SET language_id=?, content=?, string_id=? WHERE id=?"
+	return "UPDATE translation SET language_id=?, content=?, string_id=?, status=? WHERE id=?"
+}
+
+func (s Sqlite3Adapter) UpdateTranslationSourceRevisionQuery() string {
+	return "UPDATE translation SET source_revision_id=?, outdated=? WHERE id=?"
+}
+
+func (s Sqlite3Adapter) UpdateTranslationStateQuery() string {
+	return "UPDATE translation SET state=?, approved=? WHERE id=?"
+}
+
+func (s Sqlite3Adapter) UpdateTranslationStatusQuery() string {
+	return "UPDATE translation SET status=? WHERE id=?"
 }
 
 func (s Sqlite3Adapter) version(db *sqlx.DB) (version int64, err error) {
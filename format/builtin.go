@@ -0,0 +1,197 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"github.com/toolani/go-translation-api/android"
+	"github.com/toolani/go-translation-api/arb"
+	"github.com/toolani/go-translation-api/gotext"
+	"github.com/toolani/go-translation-api/ios"
+	"github.com/toolani/go-translation-api/jsonbundle"
+	"github.com/toolani/go-translation-api/po"
+	"github.com/toolani/go-translation-api/properties"
+	"github.com/toolani/go-translation-api/trans"
+	"github.com/toolani/go-translation-api/xliff"
+	"github.com/toolani/go-translation-api/yml"
+	"path/filepath"
+)
+
+// Names of the built-in formats
+const (
+	Xliff      = "xliff"
+	Po         = "po"
+	Json       = "json"
+	Yaml       = "yaml"
+	Properties = "properties"
+	Arb        = "arb"
+	Android    = "android"
+	Ios        = "ios"
+	Gotext     = "gotext"
+)
+
+func init() {
+	Register(Format{Name: Xliff, Extensions: []string{".xliff", ".xlf"}, Reader: xliffFormat{}, Writer: xliffFormat{}})
+	Register(Format{Name: Po, Extensions: []string{".po"}, Reader: poFormat{}, Writer: poFormat{}})
+	Register(Format{Name: Json, Extensions: []string{".json"}, Reader: jsonFormat{}, Writer: jsonFormat{}})
+	Register(Format{Name: Yaml, Extensions: []string{".yaml", ".yml"}, Reader: yamlFormat{}, Writer: yamlFormat{}})
+	Register(Format{Name: Properties, Extensions: []string{".properties"}, Reader: propertiesFormat{}, Writer: propertiesFormat{}})
+	Register(Format{Name: Arb, Extensions: []string{".arb"}, Reader: arbFormat{}, Writer: arbFormat{}})
+	Register(Format{Name: Android, Writer: androidFormat{}})
+	Register(Format{Name: Ios, Writer: iosFormat{}})
+	Register(Format{Name: Gotext, Writer: gotextFormat{}})
+}
+
+// Gets the domain name from a file following the '[domain].[language].[extension]' convention
+func domainFromFilename(file string) (string, error) {
+	name, _, err := trans.InfoFromFilename(filepath.Base(file))
+	return name, err
+}
+
+// Gets the domain name from a file following the '[domain]_[locale].[extension]' convention
+func domainFromLocaleFilename(file string) (string, error) {
+	name, _, err := trans.InfoFromLocaleFilename(filepath.Base(file))
+	return name, err
+}
+
+type xliffFormat struct{}
+
+func (xliffFormat) DomainName(file string) (string, error) {
+	return domainFromFilename(file)
+}
+
+// Reads an XLIFF file, checking that its source language matches that of the domain.
+func (xliffFormat) Read(file string, sourceLang trans.Language) (trans.Domain, error) {
+	x, err := xliff.NewFromFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &x.File.XliffDomain
+	if d.SourceLang != "" && d.SourceLang != sourceLang.Code {
+		return nil, errors.New(fmt.Sprintf(
+			"Found source language '%v' but expected '%v' for domain '%v' in file '%v'",
+			d.SourceLang,
+			sourceLang.Code,
+			d.Name(),
+			file))
+	}
+
+	return d, nil
+}
+func (xliffFormat) Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error {
+	version := opts.XliffVersion
+	if version == "" {
+		version = xliff.Version12
+	}
+
+	return xliff.Export(d, sourceLang, dir, version, opts.SkipSourceLanguage)
+}
+func (xliffFormat) Remove(dir, domain, lang string) error {
+	return xliff.Remove(dir, domain, lang)
+}
+
+type poFormat struct{}
+
+func (poFormat) DomainName(file string) (string, error) {
+	return domainFromFilename(file)
+}
+func (poFormat) Read(file string, sourceLang trans.Language) (trans.Domain, error) {
+	return po.NewFromFile(file)
+}
+func (poFormat) Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error {
+	return po.Export(d, sourceLang, dir, opts.SkipSourceLanguage)
+}
+func (poFormat) Remove(dir, domain, lang string) error {
+	return po.Remove(dir, domain, lang)
+}
+
+type jsonFormat struct{}
+
+func (jsonFormat) DomainName(file string) (string, error) {
+	return domainFromFilename(file)
+}
+func (jsonFormat) Read(file string, sourceLang trans.Language) (trans.Domain, error) {
+	return jsonbundle.NewFromFile(file)
+}
+func (jsonFormat) Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error {
+	return jsonbundle.Export(d, sourceLang, dir, opts.Nested, opts.SkipSourceLanguage)
+}
+func (jsonFormat) Remove(dir, domain, lang string) error {
+	return jsonbundle.Remove(dir, domain, lang)
+}
+
+type yamlFormat struct{}
+
+func (yamlFormat) DomainName(file string) (string, error) {
+	return domainFromFilename(file)
+}
+func (yamlFormat) Read(file string, sourceLang trans.Language) (trans.Domain, error) {
+	return yml.NewFromFile(file)
+}
+func (yamlFormat) Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error {
+	return yml.Export(d, sourceLang, dir, opts.SkipSourceLanguage)
+}
+func (yamlFormat) Remove(dir, domain, lang string) error {
+	return yml.Remove(dir, domain, lang)
+}
+
+type propertiesFormat struct{}
+
+func (propertiesFormat) DomainName(file string) (string, error) {
+	return domainFromLocaleFilename(file)
+}
+
+// Reads a .properties file. Base bundles, which have no locale, are read as the source language.
+func (propertiesFormat) Read(file string, sourceLang trans.Language) (trans.Domain, error) {
+	return properties.NewFromFile(file, sourceLang.Code)
+}
+func (propertiesFormat) Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error {
+	return properties.Export(d, sourceLang, dir, opts.SkipSourceLanguage)
+}
+func (propertiesFormat) Remove(dir, domain, lang string) error {
+	return properties.Remove(dir, domain, lang)
+}
+
+type arbFormat struct{}
+
+func (arbFormat) DomainName(file string) (string, error) {
+	return domainFromLocaleFilename(file)
+}
+
+// Reads an ARB file. Files without a locale are read as the source language.
+func (arbFormat) Read(file string, sourceLang trans.Language) (trans.Domain, error) {
+	return arb.NewFromFile(file, sourceLang.Code)
+}
+func (arbFormat) Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error {
+	return arb.Export(d, sourceLang, dir, opts.SkipSourceLanguage)
+}
+func (arbFormat) Remove(dir, domain, lang string) error {
+	return arb.Remove(dir, domain, lang)
+}
+
+type androidFormat struct{}
+
+func (androidFormat) Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error {
+	return android.Export(d, sourceLang, dir, opts.SkipSourceLanguage)
+}
+func (androidFormat) Remove(dir, domain, lang string) error {
+	return android.Remove(dir, domain, lang)
+}
+
+type iosFormat struct{}
+
+func (iosFormat) Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error {
+	return ios.Export(d, sourceLang, dir, opts.SkipSourceLanguage)
+}
+func (iosFormat) Remove(dir, domain, lang string) error {
+	return ios.Remove(dir, domain, lang)
+}
+
+type gotextFormat struct{}
+
+func (gotextFormat) Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error {
+	return gotext.Export(d, sourceLang, dir, opts.GoCatalog, opts.SkipSourceLanguage)
+}
+func (gotextFormat) Remove(dir, domain, lang string) error {
+	return gotext.Remove(dir, domain, lang)
+}
@@ -0,0 +1,129 @@
+/*
+Package format provides a registry of the file formats that translations can be imported from and
+exported to.
+
+Each format is registered under a unique name, along with the file extensions that it reads.
+Formats that can be imported provide a Reader, and formats that can be exported provide a Writer.
+The built-in formats are registered when the package is initialised. Other formats can be added by
+calling Register, e.g. from the init function of the package implementing them.
+*/
+package format
+
+import (
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"strings"
+)
+
+// Options holds settings that affect how some formats are written.
+type Options struct {
+	// Whether to write nested rather than flat keys, for formats that support both
+	Nested bool
+	// Version of XLIFF documents to write, one of the xliff.Version* constants
+	XliffVersion string
+	// Whether to generate Go source files, for formats used by Go programs
+	GoCatalog bool
+	// Whether to leave out the files of the source language, whose translations are then only used
+	// as the source text of the other languages
+	SkipSourceLanguage bool
+}
+
+// Reader reads translation domains from files.
+type Reader interface {
+	// DomainName gets the name of the domain that the file at the given path contains translations
+	// for, without reading the file.
+	DomainName(file string) (string, error)
+	// Read reads the domain from the file at the given path. sourceLang is the domain's source
+	// language.
+	Read(file string, sourceLang trans.Language) (trans.Domain, error)
+}
+
+// Writer writes translation domains to files.
+type Writer interface {
+	// Write exports the domain to files in dir, one for each language that it has translations for,
+	// except for the source language if opts.SkipSourceLanguage is true.
+	Write(d trans.Domain, sourceLang trans.Language, dir string, opts Options) error
+	// Remove removes previously written files from dir. Only files belonging to the given domain and
+	// language are removed, where an empty domain or language matches any.
+	Remove(dir, domain, lang string) error
+}
+
+// Format describes a registered file format.
+type Format struct {
+	// Unique name of the format, as used in config files and the 'format' query parameter
+	Name string
+	// Extensions of the files that the format reads, including the leading '.'
+	Extensions []string
+	// Reads the format's files, or nil if the format cannot be imported
+	Reader Reader
+	// Writes the format's files, or nil if the format cannot be exported
+	Writer Writer
+}
+
+// Registered formats, in the order that they were registered
+var formats []Format
+
+// Register makes a format available for importing and exporting. It panics if a format with the
+// same name, or one reading any of the same file extensions, is already registered.
+func Register(f Format) {
+	if f.Reader == nil && f.Writer == nil {
+		panic(fmt.Sprintf("format: format '%v' has neither a Reader nor a Writer", f.Name))
+	}
+	if _, ok := Get(f.Name); ok {
+		panic(fmt.Sprintf("format: format '%v' is already registered", f.Name))
+	}
+	for _, ext := range f.Extensions {
+		if other, ok := ForExtension(ext); ok {
+			panic(fmt.Sprintf("format: extension '%v' is already registered by format '%v'", ext, other.Name))
+		}
+	}
+
+	formats = append(formats, f)
+}
+
+// Get gets the format with the given name.
+func Get(name string) (f Format, ok bool) {
+	for _, f := range formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return f, false
+}
+
+// ForExtension gets the format that reads files with the given extension, e.g. '.xliff'.
+func ForExtension(ext string) (f Format, ok bool) {
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if strings.EqualFold(e, ext) {
+				return f, true
+			}
+		}
+	}
+
+	return f, false
+}
+
+// Readable gets all formats that can be imported, in the order that they were registered.
+func Readable() (fs []Format) {
+	for _, f := range formats {
+		if f.Reader != nil {
+			fs = append(fs, f)
+		}
+	}
+
+	return fs
+}
+
+// WritableNames gets the names of all formats that can be exported, in the order that they were
+// registered.
+func WritableNames() (names []string) {
+	for _, f := range formats {
+		if f.Writer != nil {
+			names = append(names, f.Name)
+		}
+	}
+
+	return names
+}
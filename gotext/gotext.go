@@ -0,0 +1,318 @@
+/*
+Package gotext implements exporting of translations for Go programs using golang.org/x/text/message.
+
+Each domain is exported to its own directory, laid out in the same way as the packages handled by
+the gotext tool, with a '[domain]/locales/[locale]/messages.gotext.json' file for each language.
+Each message's id is the string's name, so that it is used as the key when printing, e.g.
+'p.Sprintf("homepage.title")'.
+
+A '[domain]/catalog.go' file can also be generated, which builds a catalog.Catalog holding the
+domain's translations. The directory can then be imported as a Go package, named after the domain.
+
+Strings whose source language content contains plural forms separated by '|' characters are
+written as messages that select a plural form using their first argument, e.g.
+'p.Sprintf("apples", 3)'. Symfony's '%count%' placeholder in plural forms is written as a reference
+to that argument. As messages are format strings, any other '%' characters are escaped as '%%'.
+*/
+package gotext
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Name of the files that each language's messages are written to
+	messagesFile = "messages.gotext.json"
+	// Name of the generated Go source file
+	catalogFile = "catalog.go"
+
+	// Name of the placeholder holding the number that selects a plural form
+	countPlaceholder = "Count"
+)
+
+type messages struct {
+	Language string     `json:"language"`
+	Messages []*message `json:"messages"`
+}
+
+type message struct {
+	Id                string         `json:"id"`
+	Message           interface{}    `json:"message"`
+	Translation       interface{}    `json:"translation"`
+	TranslatorComment string         `json:"translatorComment,omitempty"`
+	Placeholders      []*placeholder `json:"placeholders,omitempty"`
+}
+
+// A choice between texts, written in place of a message's text
+type selection struct {
+	Select struct {
+		Feature string            `json:"feature"`
+		Arg     string            `json:"arg"`
+		Cases   map[string]string `json:"cases"`
+	} `json:"select"`
+}
+
+type placeholder struct {
+	Id             string `json:"id"`
+	String         string `json:"string"`
+	Type           string `json:"type"`
+	UnderlyingType string `json:"underlyingType"`
+	ArgNum         int    `json:"argNum"`
+}
+
+// Tag converts a language code to a BCP 47 language tag, e.g. 'de-ch' to 'de-CH'.
+func Tag(code string) string {
+	parts := strings.Split(code, "-")
+	for i, p := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(p)
+		case len(p) == 4:
+			// Script, e.g. 'Hant'
+			parts[i] = strings.ToUpper(p[:1]) + strings.ToLower(p[1:])
+		case len(p) == 2:
+			parts[i] = strings.ToUpper(p)
+		default:
+			parts[i] = strings.ToLower(p)
+		}
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// PackageName gets the name of the Go package that a domain's catalog is generated in. Characters
+// that may not be used in package names are replaced by underscores.
+func PackageName(domain string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, domain)
+
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "domain" + name
+	}
+	if token.IsKeyword(name) {
+		name = name + "_"
+	}
+
+	return name
+}
+
+func getTranslation(s trans.String, l trans.Language) (t trans.Translation) {
+	if t, ok := s.Translations()[l]; ok {
+		return t
+	}
+
+	return nil
+}
+
+// Escapes the '%' characters of content, as messages are format strings
+func escapeFormat(content string) string {
+	return strings.Replace(content, "%", "%%", -1)
+}
+
+// Gets the text that content should be written as, which is a selection of plural forms when the
+// source text contains plural forms. The '%count%' placeholder of each plural form refers to the
+// message's count placeholder.
+func text(sourceText, content string) interface{} {
+	forms := trans.PluralForms(content)
+	categories := trans.PluralCategories(len(forms))
+	if len(trans.PluralForms(sourceText)) < 2 || categories == nil {
+		return escapeFormat(content)
+	}
+
+	sel := &selection{}
+	sel.Select.Feature = "plural"
+	sel.Select.Arg = countPlaceholder
+	sel.Select.Cases = make(map[string]string)
+	for i, f := range forms {
+		sel.Select.Cases[categories[i]] = strings.Replace(escapeFormat(f), "%%count%%", "{"+countPlaceholder+"}", -1)
+	}
+
+	return sel
+}
+
+// Exports the domain to a '[domain]/locales/[locale]/messages.gotext.json' file in dir for each
+// language that it has translations for. If generateCatalog is true, a '[domain]/catalog.go' file
+// is also written. When skipSource is true, the source language's messages are left out of both.
+func Export(source trans.Domain, sourceLang trans.Language, dir string, generateCatalog, skipSource bool) (err error) {
+	files := make(map[string]*messages)
+
+	strs := append([]trans.String(nil), source.Strings()...)
+	sort.Slice(strs, func(i, j int) bool { return strs[i].Name() < strs[j].Name() })
+
+	for _, s := range strs {
+		sourceText := s.Name()
+		if sourceTrans := getTranslation(s, sourceLang); sourceTrans != nil {
+			sourceText = sourceTrans.Content()
+		}
+
+		for l, t := range s.Translations() {
+			if skipSource && l.Code == sourceLang.Code {
+				continue
+			}
+			if _, ok := files[l.Code]; !ok {
+				files[l.Code] = &messages{Language: Tag(l.Code), Messages: make([]*message, 0)}
+			}
+
+			m := &message{
+				Id:                s.Name(),
+				Message:           text(sourceText, sourceText),
+				Translation:       text(sourceText, t.Content()),
+				TranslatorComment: strings.Join(trans.InfoOf(s).Notes, "\n"),
+			}
+			if _, plural := m.Message.(*selection); plural {
+				m.Placeholders = []*placeholder{{
+					Id:             countPlaceholder,
+					String:         "%[1]d",
+					Type:           "int",
+					UnderlyingType: "int",
+					ArgNum:         1,
+				}}
+			}
+			files[l.Code].Messages = append(files[l.Code].Messages, m)
+		}
+	}
+
+	domainDir := filepath.Join(dir, source.Name())
+	for code, msgs := range files {
+		localeDir := filepath.Join(domainDir, "locales", Tag(code))
+		err = os.MkdirAll(localeDir, 0755)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "    ")
+		if err = enc.Encode(msgs); err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(localeDir, messagesFile), buf.Bytes(), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !generateCatalog {
+		return nil
+	}
+
+	src, err := catalogSource(source.Name(), sourceLang, files)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(domainDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(domainDir, catalogFile), src, 0644)
+}
+
+// Generates the Go source of a catalog holding the given messages for each language
+func catalogSource(domain string, sourceLang trans.Language, files map[string]*messages) ([]byte, error) {
+	codes := make([]string, 0, len(files))
+	plurals := false
+	for code, msgs := range files {
+		codes = append(codes, code)
+		for _, m := range msgs.Messages {
+			if _, ok := m.Translation.(*selection); ok {
+				plurals = true
+			}
+		}
+	}
+	sort.Strings(codes)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by go-translation-api. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %v\n\n", PackageName(domain))
+	fmt.Fprintf(&b, "import (\n")
+	if plurals {
+		fmt.Fprintf(&b, "\"golang.org/x/text/feature/plural\"\n")
+	}
+	fmt.Fprintf(&b, "\"golang.org/x/text/language\"\n")
+	fmt.Fprintf(&b, "\"golang.org/x/text/message/catalog\"\n")
+	fmt.Fprintf(&b, ")\n\n")
+
+	fmt.Fprintf(&b, "// Catalog holds the translations of the %v domain. Use it by creating a printer with\n", strconv.Quote(domain))
+	fmt.Fprintf(&b, "// message.NewPrinter(tag, message.Catalog(Catalog)).\n")
+	fmt.Fprintf(&b, "var Catalog catalog.Catalog = newCatalog()\n\n")
+
+	fmt.Fprintf(&b, "func newCatalog() *catalog.Builder {\n")
+	fmt.Fprintf(&b, "b := catalog.NewBuilder(catalog.Fallback(language.MustParse(%v)))\n", strconv.Quote(Tag(sourceLang.Code)))
+	fmt.Fprintf(&b, "var tag language.Tag\n")
+	for _, code := range codes {
+		fmt.Fprintf(&b, "\ntag = language.MustParse(%v)\n", strconv.Quote(Tag(code)))
+		for _, m := range files[code].Messages {
+			switch t := m.Translation.(type) {
+			case string:
+				fmt.Fprintf(&b, "b.SetString(tag, %v, %v)\n", strconv.Quote(m.Id), strconv.Quote(t))
+			case *selection:
+				// Cases refer to the count by its placeholder, which is the selected argument
+				count := strings.NewReplacer("{"+countPlaceholder+"}", "%d")
+				fmt.Fprintf(&b, "b.Set(tag, %v, plural.Selectf(1, \"%%d\"", strconv.Quote(m.Id))
+				for _, c := range trans.AllPluralCategories {
+					if f, ok := t.Select.Cases[c]; ok {
+						fmt.Fprintf(&b, ", %v, %v", strconv.Quote(c), strconv.Quote(count.Replace(f)))
+					}
+				}
+				fmt.Fprintf(&b, "))\n")
+			}
+		}
+	}
+	fmt.Fprintf(&b, "\nreturn b\n")
+	fmt.Fprintf(&b, "}\n")
+
+	return format.Source(b.Bytes())
+}
+
+// Removes previously exported files from dir. Only files belonging to the given domain and language
+// are removed, where an empty domain or language matches any. A domain's generated catalog is
+// removed along with the files of all of its languages.
+func Remove(dir, domain, lang string) (err error) {
+	locale := "*"
+	if lang != "" {
+		locale = Tag(lang)
+	}
+	if domain == "" {
+		domain = "*"
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, domain, "locales", locale, messagesFile))
+	if err != nil {
+		return err
+	}
+	if lang == "" {
+		catalogs, err := filepath.Glob(filepath.Join(dir, domain, catalogFile))
+		if err != nil {
+			return err
+		}
+		files = append(files, catalogs...)
+	}
+
+	for _, file := range files {
+		if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
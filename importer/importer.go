@@ -1,14 +1,55 @@
 package importer
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/toolani/go-translation-api/config"
 	"github.com/toolani/go-translation-api/datastore"
+	"github.com/toolani/go-translation-api/trans"
 	"os"
+	"os/user"
+	"strings"
 	"time"
 )
 
+// Options controls how Import runs.
+type Options struct {
+	// Roll back all changes once the import has finished, only reporting what would have changed
+	DryRun bool
+	// Print a JSON summary of the changes instead of the list of imported files
+	Json bool
+	// One of the config.ImportPolicy* constants, overriding the configured import policy if set
+	Policy string
+	// Archive strings that are missing from their domain's imported source language files
+	Sync bool
+	// Allow syncing to archive more of a domain's strings than the configured threshold
+	Force bool
+}
+
+// Summary describes the effect of an import, as printed when the Json option is set.
+type Summary struct {
+	DryRun       bool                   `json:"dry_run"`
+	Policy       string                 `json:"policy"`
+	Sync         bool                   `json:"sync"`
+	Files        []string               `json:"files"`
+	SkippedFiles []datastore.SkippedFile `json:"skipped_files"`
+	Strings      datastore.ChangeCounts `json:"strings"`
+	Translations datastore.ChangeCounts `json:"translations"`
+	Changes      datastore.ImportDiff   `json:"changes"`
+}
+
+// Author gets the name recorded as the author of imported changes, which is the name of the user
+// running the program.
+func Author() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
+
 func checkFatal(err error) {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err)
@@ -16,40 +57,154 @@ func checkFatal(err error) {
 	}
 }
 
-func Import(c config.Config) {
+// Import imports the files in the configured import directories within a single transaction, so
+// that nothing is imported if any file fails to import. With the config.ImportPolicyFailOnConflict
+// policy, nothing is imported if any of the files' translations conflicts with an existing one.
+// When syncing, strings that are missing from the imported files of their domain's source language
+// are archived once all files have been imported.
+func Import(c config.Config, opts Options) {
 	start := time.Now()
 
+	policy := c.XLIFF.ImportPolicy
+	if opts.Policy != "" {
+		if !config.ValidImportPolicy(opts.Policy) {
+			checkFatal(errors.New(fmt.Sprintf("Invalid import policy '%v'. (Must be one of: '%v')", opts.Policy, strings.Join(config.ImportPolicies, ", "))))
+		}
+		policy = opts.Policy
+	}
+
 	results := make(chan string, 100)
 	done := make(chan bool, 1)
 
+	files := make([]string, 0)
 	go func() {
-		for {
-			imported := <-results
-			fmt.Println("Imported domain: ", imported)
+		for imported := range results {
+			files = append(files, imported)
+			if !opts.Json {
+				fmt.Println("Imported domain: ", imported)
+			}
 		}
+		done <- true
 	}()
 
-	var (
-		count int
-		stats datastore.Stats
-	)
-	go func() {
-		var db *sqlx.DB
-		db, err := sqlx.Connect(c.DB.Driver, c.DB.ConnectionString())
-		checkFatal(err)
-		ds, err := datastore.New(db, c.DB.Driver)
-		checkFatal(err)
-		count, err = ds.ImportDir(c.XLIFF.ImportPath, results)
-		checkFatal(err)
+	var db *sqlx.DB
+	db, err := sqlx.Connect(c.DB.Driver, c.DB.ConnectionString())
+	checkFatal(err)
+	ds, err := datastore.New(db, c.DB.Driver)
+	checkFatal(err)
+	ds.SourceLanguage = c.XLIFF.SourceLanguage
+	ds.ImportPolicy = policy
+	ds.RevisionSource = datastore.RevisionSourceImport
+	ds.RevisionAuthor = Author()
 
-		stats = ds.Stats
+	err = trans.SetFilenamePattern(c.XLIFF.FilenamePattern)
+	checkFatal(err)
 
-		done <- true
-	}()
+	dirs, err := c.XLIFF.ImportDirs()
+	checkFatal(err)
+
+	err = ds.Begin()
+	checkFatal(err)
+
+	_, err = ds.ImportDirs(dirs, c.XLIFF.ImportRecursive, results)
+	close(results)
 	<-done
 
-	elapsed := time.Since(start).Seconds()
-	fmt.Printf("Imported %v files in %fs\n\n", count, elapsed)
+	if err == nil && opts.Sync {
+		err = ds.SyncDomains(c.XLIFF.SyncThreshold, opts.Force)
+	}
+
+	if err != nil {
+		if rbErr := ds.Rollback(); rbErr != nil {
+			fmt.Fprintln(os.Stderr, "Error:", rbErr)
+		}
+		checkFatal(errors.New(fmt.Sprintf("%v (the import was rolled back, no changes were made)", err)))
+	}
+
+	var conflictErr error
+	if n := len(ds.Diff.Conflicts()); n > 0 && policy == config.ImportPolicyFailOnConflict {
+		conflictErr = errors.New(fmt.Sprintf("%v translations conflict with those in the database (the import was rolled back, no changes were made)", n))
+	}
+
+	if opts.DryRun || conflictErr != nil {
+		err = ds.Rollback()
+	} else {
+		err = ds.Commit()
+	}
+	checkFatal(err)
+
+	summary := Summary{
+		DryRun:       opts.DryRun,
+		Policy:       policy,
+		Sync:         opts.Sync,
+		Files:        files,
+		SkippedFiles: ds.SkippedFiles,
+		Strings:      ds.Diff.StringCounts(),
+		Translations: ds.Diff.TranslationCounts(),
+		Changes:      ds.Diff,
+	}
+
+	if opts.Json {
+		output, err := json.MarshalIndent(summary, "", "    ")
+		checkFatal(err)
+		fmt.Println(string(output))
+	} else {
+		printSummary(summary, time.Since(start))
+	}
+
+	fmt.Fprintln(os.Stderr, ds.Stats)
+
+	checkFatal(conflictErr)
+}
+
+// Prints a summary of an import. The individual changes are only listed for dry runs, but conflicts
+// are always listed.
+func printSummary(s Summary, elapsed time.Duration) {
+	were := "were"
+	if s.DryRun {
+		were = "would be"
+	}
+
+	if archived := s.Changes.Archived(); len(archived) > 0 {
+		fmt.Printf("\nStrings that are no longer in their domain's source language files, which %v archived:\n", were)
+		for _, sc := range archived {
+			fmt.Println(sc)
+		}
+	}
+
+	if len(s.SkippedFiles) > 0 {
+		fmt.Println("\nFiles that were skipped, as their language does not exist in the database:")
+		for _, sf := range s.SkippedFiles {
+			fmt.Println(sf)
+		}
+	}
+
+	if conflicts := s.Changes.Conflicts(); len(conflicts) > 0 {
+		fmt.Printf("\nTranslations that conflict with those in the database, which %v not imported:\n", were)
+		for _, tc := range conflicts {
+			fmt.Println(tc)
+		}
+	}
+
+	if s.DryRun {
+		fmt.Println("\nChanges that would be made:")
+		for _, sc := range s.Changes.Strings {
+			if sc.Action == datastore.ChangeCreated || sc.Action == datastore.ChangeRestored {
+				fmt.Println(sc)
+			}
+		}
+		for _, tc := range s.Changes.Translations {
+			if tc.Action == datastore.ChangeCreated || tc.Action == datastore.ChangeUpdated {
+				fmt.Println(tc)
+			}
+		}
+		fmt.Printf("\nDry run of %v files in %fs, no changes were made\n", len(s.Files), elapsed.Seconds())
+	} else {
+		fmt.Printf("Imported %v files in %fs\n", len(s.Files), elapsed.Seconds())
+	}
 
-	fmt.Fprintln(os.Stderr, stats)
+	fmt.Printf("Strings: %v created, %v restored, %v unchanged, %v skipped, %v archived\n",
+		s.Strings.Created, s.Strings.Restored, s.Strings.Unchanged, s.Strings.Skipped, s.Strings.Archived)
+	fmt.Printf("Translations: %v created, %v updated, %v unchanged, %v skipped, %v conflicts\n\n",
+		s.Translations.Created, s.Translations.Updated, s.Translations.Unchanged, s.Translations.Skipped, s.Translations.Conflicts)
 }
@@ -0,0 +1,193 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/toolani/go-translation-api/datastore"
+	"github.com/toolani/go-translation-api/sheet"
+	"github.com/toolani/go-translation-api/trans"
+)
+
+// CellChange is a cell of an imported sheet whose content differs from that in the database.
+type CellChange struct {
+	Row        int
+	Domain     string
+	StringName string
+	Language   string
+	// Content in the database before the import, which is empty if there was no translation
+	Old string
+	New string
+}
+
+func (cc CellChange) String() string {
+	return fmt.Sprintf("Row %v: '%v' in domain '%v' [%v]: '%v' -> '%v'", cc.Row, cc.StringName, cc.Domain, cc.Language, cc.Old, cc.New)
+}
+
+// RefusedRow is a row of an imported sheet that could not be imported.
+type RefusedRow struct {
+	Row    int
+	Reason string
+}
+
+func (rr RefusedRow) String() string {
+	return fmt.Sprintf("Row %v: %v", rr.Row, rr.Reason)
+}
+
+// Domain holding the changed cells of a sheet, for importing
+type sheetDomain struct {
+	name    string
+	strings map[string]*sheetString
+	// String names in the order that they were added
+	order []string
+}
+
+func (sd *sheetDomain) Name() string {
+	return sd.name
+}
+func (sd *sheetDomain) SetName(name string) {
+	sd.name = name
+}
+func (sd *sheetDomain) Strings() []trans.String {
+	ss := make([]trans.String, len(sd.order))
+	for i, name := range sd.order {
+		ss[i] = sd.strings[name]
+	}
+
+	return ss
+}
+
+type sheetString struct {
+	name         string
+	translations map[trans.Language]trans.Translation
+}
+
+func (ss *sheetString) Name() string {
+	return ss.name
+}
+func (ss *sheetString) Translations() map[trans.Language]trans.Translation {
+	return ss.translations
+}
+
+type sheetTranslation string
+
+func (st sheetTranslation) Content() string {
+	return string(st)
+}
+
+// Gets the content of each translation of the named domain in the database, by string name and
+// language code
+func getContents(ds *datastore.DataStore, name string) (contents map[string]map[string]string, err error) {
+	contents = make(map[string]map[string]string)
+
+	d, err := ds.GetFullDomain(name)
+	if err == sql.ErrNoRows {
+		return contents, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range d.Strings() {
+		contents[s.Name()] = make(map[string]string)
+		for l, t := range s.Translations() {
+			contents[s.Name()][l.Code] = t.Content()
+		}
+	}
+
+	return contents, nil
+}
+
+// ImportSheet imports the cells of a sheet whose content differs from that in the database. Empty
+// cells are ignored, so translations cannot be removed by importing a sheet. Rows whose domain, or
+// the language of any of whose non-empty cells, does not exist in the database are not imported.
+func ImportSheet(ds *datastore.DataStore, s *sheet.Sheet) (changes []CellChange, refused []RefusedRow, err error) {
+	languages, err := ds.GetLanguageList()
+	if err != nil {
+		return nil, nil, err
+	}
+	knownLangs := make(map[string]bool)
+	for _, l := range languages {
+		knownLangs[l.Code] = true
+	}
+
+	domainList, err := ds.GetDomainList()
+	if err != nil {
+		return nil, nil, err
+	}
+	contents := make(map[string]map[string]map[string]string)
+	for _, d := range domainList {
+		contents[d.Name()] = nil
+	}
+
+	domains := make(map[string]*sheetDomain)
+	var domainOrder []string
+
+	for _, row := range s.Rows {
+		dc, ok := contents[row.Domain]
+		if !ok {
+			refused = append(refused, RefusedRow{row.Number, fmt.Sprintf("Domain '%v' does not exist in database", row.Domain)})
+			continue
+		}
+		if row.String == "" {
+			refused = append(refused, RefusedRow{row.Number, "Missing string name"})
+			continue
+		}
+
+		unknown := ""
+		for _, code := range s.Languages {
+			if _, ok := row.Contents[code]; ok && !knownLangs[code] {
+				unknown = code
+				break
+			}
+		}
+		if unknown != "" {
+			refused = append(refused, RefusedRow{row.Number, fmt.Sprintf("Language '%v' does not exist in database", unknown)})
+			continue
+		}
+
+		// Load the domain's current content the first time that it is needed
+		if dc == nil {
+			dc, err = getContents(ds, row.Domain)
+			if err != nil {
+				return nil, nil, err
+			}
+			contents[row.Domain] = dc
+		}
+
+		for _, code := range s.Languages {
+			content, ok := row.Contents[code]
+			if !ok {
+				continue
+			}
+			old, exists := dc[row.String][code]
+			if exists && old == content {
+				continue
+			}
+
+			changes = append(changes, CellChange{row.Number, row.Domain, row.String, code, old, content})
+
+			d, ok := domains[row.Domain]
+			if !ok {
+				d = &sheetDomain{name: row.Domain, strings: make(map[string]*sheetString)}
+				domains[row.Domain] = d
+				domainOrder = append(domainOrder, row.Domain)
+			}
+			str, ok := d.strings[row.String]
+			if !ok {
+				str = &sheetString{name: row.String, translations: make(map[trans.Language]trans.Translation)}
+				d.strings[row.String] = str
+				d.order = append(d.order, row.String)
+			}
+			str.translations[trans.Language{Code: code}] = sheetTranslation(content)
+		}
+	}
+
+	for _, name := range domainOrder {
+		err = ds.ImportDomain(domains[name])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return changes, refused, nil
+}
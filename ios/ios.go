@@ -0,0 +1,176 @@
+/*
+Package ios implements exporting of translations to Apple .strings and .stringsdict files.
+
+Each domain is exported as a strings table named after the domain, with a
+'[locale].lproj/[domain].strings' file for each language. Strings whose source language content
+contains plural forms separated by '|' characters are written to a
+'[locale].lproj/[domain].stringsdict' file instead, using the NSStringPluralRuleType format, with
+Symfony's '%count%' placeholder written as the '%d' format specifier.
+*/
+package ios
+
+import (
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Locale converts a language code to an Apple locale identifier, e.g. 'de-ch' to 'de-CH'.
+func Locale(code string) string {
+	parts := strings.SplitN(code, "-", 2)
+	if len(parts) == 1 {
+		return strings.ToLower(parts[0])
+	}
+
+	return fmt.Sprintf("%v-%v", strings.ToLower(parts[0]), strings.ToUpper(parts[1]))
+}
+
+// Escapes text for use in a quoted .strings file key or value
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(s)
+}
+
+// Escapes text for use in a property list
+func escapeXml(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func getTranslation(s trans.String, l trans.Language) (t trans.Translation) {
+	if t, ok := s.Translations()[l]; ok {
+		return t
+	}
+
+	return nil
+}
+
+// Converts a plural form to a .stringsdict format string. Symfony's '%count%' placeholder becomes the
+// '%d' format specifier for the count, and any other '%' characters are escaped.
+func pluralFormat(form string) string {
+	return strings.Replace(strings.Replace(form, "%", "%%", -1), "%%count%%", "%d", -1)
+}
+
+// The .strings and .stringsdict content for a single language
+type table struct {
+	strs    strings.Builder
+	plurals strings.Builder
+}
+
+// Writes a single string to the table's .strings or .stringsdict content
+func (t *table) add(name, sourceText, content string) {
+	forms := trans.PluralForms(content)
+	categories := trans.PluralCategories(len(forms))
+	if len(trans.PluralForms(sourceText)) < 2 || categories == nil {
+		fmt.Fprintf(&t.strs, "\"%v\" = \"%v\";\n", escape(name), escape(content))
+		return
+	}
+
+	fmt.Fprintf(&t.plurals, "    <key>%v</key>\n", escapeXml(name))
+	fmt.Fprintf(&t.plurals, "    <dict>\n")
+	fmt.Fprintf(&t.plurals, "        <key>NSStringLocalizedFormatKey</key>\n")
+	fmt.Fprintf(&t.plurals, "        <string>%%#@count@</string>\n")
+	fmt.Fprintf(&t.plurals, "        <key>count</key>\n")
+	fmt.Fprintf(&t.plurals, "        <dict>\n")
+	fmt.Fprintf(&t.plurals, "            <key>NSStringFormatSpecTypeKey</key>\n")
+	fmt.Fprintf(&t.plurals, "            <string>NSStringPluralRuleType</string>\n")
+	fmt.Fprintf(&t.plurals, "            <key>NSStringFormatValueTypeKey</key>\n")
+	fmt.Fprintf(&t.plurals, "            <string>d</string>\n")
+	for i, f := range forms {
+		fmt.Fprintf(&t.plurals, "            <key>%v</key>\n", categories[i])
+		fmt.Fprintf(&t.plurals, "            <string>%v</string>\n", escapeXml(pluralFormat(f)))
+	}
+	fmt.Fprintf(&t.plurals, "        </dict>\n")
+	fmt.Fprintf(&t.plurals, "    </dict>\n")
+}
+
+// Exports the domain to '[locale].lproj/[domain].strings' (and '.stringsdict') files in dir, one
+// for each language that the domain has translations for. When skipSource is true, no files are
+// written for the source language.
+func Export(source trans.Domain, sourceLang trans.Language, dir string, skipSource bool) (err error) {
+	tables := make(map[string]*table)
+
+	strs := append([]trans.String(nil), source.Strings()...)
+	sort.Slice(strs, func(i, j int) bool { return strs[i].Name() < strs[j].Name() })
+
+	for _, s := range strs {
+		sourceText := s.Name()
+		if sourceTrans := getTranslation(s, sourceLang); sourceTrans != nil {
+			sourceText = sourceTrans.Content()
+		}
+
+		for l, t := range s.Translations() {
+			if _, ok := tables[l.Code]; !ok {
+				tables[l.Code] = &table{}
+			}
+			tables[l.Code].add(s.Name(), sourceText, t.Content())
+		}
+	}
+
+	for code, t := range tables {
+		if skipSource && code == sourceLang.Code {
+			continue
+		}
+
+		lproj := filepath.Join(dir, Locale(code)+".lproj")
+		err = os.MkdirAll(lproj, 0755)
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(lproj, source.Name()+".strings"), []byte(t.strs.String()), 0644)
+		if err != nil {
+			return err
+		}
+
+		dictFile := filepath.Join(lproj, source.Name()+".stringsdict")
+		if t.plurals.Len() == 0 {
+			// Remove any plurals left over from a previous export
+			if err = os.Remove(dictFile); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+
+		dict := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+			"<!DOCTYPE plist PUBLIC \"-//Apple//DTD PLIST 1.0//EN\" \"http://www.apple.com/DTDs/PropertyList-1.0.dtd\">\n" +
+			"<plist version=\"1.0\">\n<dict>\n" +
+			t.plurals.String() +
+			"</dict>\n</plist>\n"
+		err = ioutil.WriteFile(dictFile, []byte(dict), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Removes previously exported .strings and .stringsdict files from dir. Only files belonging to the
+// given domain and language are removed, where an empty domain or language matches any.
+func Remove(dir, domain, lang string) (err error) {
+	lproj := "*.lproj"
+	if lang != "" {
+		lproj = Locale(lang) + ".lproj"
+	}
+	if domain == "" {
+		domain = "*"
+	}
+
+	for _, ext := range []string{".strings", ".stringsdict"} {
+		files, err := filepath.Glob(filepath.Join(dir, lproj, domain+ext))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,298 @@
+/*
+Package jsonbundle implements reading and writing of JSON message catalogs, as used by front-end
+translation libraries such as i18next.
+
+Each file contains the translations of a single domain into a single language. Translations are
+written either as a flat object keyed by string name, or as nested objects made by splitting string
+names on '.' characters, e.g. 'homepage.title.main'.
+
+Strings whose source language content contains plural forms separated by '|' characters are
+written as one key per plural form, using i18next's plural suffixes (e.g. 'apples_one' and
+'apples_other').
+*/
+package jsonbundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Separates the parts of a key when nesting
+const keySeparator = "."
+
+type JsonDomain struct {
+	name     string
+	Language string
+	Messages []*JsonString
+}
+
+func (jd JsonDomain) Name() string {
+	return jd.name
+}
+func (jd *JsonDomain) SetName(name string) {
+	jd.name = name
+}
+func (jd JsonDomain) Strings() []trans.String {
+	ss := make([]trans.String, len(jd.Messages))
+	for i, m := range jd.Messages {
+		ss[i] = m
+	}
+
+	return ss
+}
+
+type JsonString struct {
+	language *trans.Language
+	Key      string
+	Value    string
+}
+
+func (js JsonString) Name() string {
+	return js.Key
+}
+func (js JsonString) Translations() map[trans.Language]trans.Translation {
+	ts := make(map[trans.Language]trans.Translation)
+	ts[*js.language] = js
+
+	return ts
+}
+func (js JsonString) Content() string {
+	return js.Value
+}
+
+// Creates a new JsonDomain from the file at the given path. Nested objects are flattened, so that
+// their keys are joined with '.' characters, and keys with plural suffixes are combined into a
+// single string.
+func NewFromFile(file string) (jd *JsonDomain, err error) {
+	name, lang, err := trans.InfoFromFilename(filepath.Base(file))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var root map[string]interface{}
+	err = json.Unmarshal(data, &root)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v in file '%v'", err, file))
+	}
+
+	flat := make(map[string]string)
+	err = flatten("", root, flat)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v in file '%v'", err, file))
+	}
+	joinPlurals(flat)
+
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	l := trans.Language{Code: lang}
+	jd = &JsonDomain{name: name, Language: lang}
+	for _, k := range keys {
+		jd.Messages = append(jd.Messages, &JsonString{language: &l, Key: k, Value: flat[k]})
+	}
+
+	return jd, nil
+}
+
+func flatten(prefix string, obj map[string]interface{}, out map[string]string) (err error) {
+	for k, v := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + keySeparator + k
+		}
+
+		switch v := v.(type) {
+		case string:
+			if _, ok := out[key]; ok {
+				return errors.New(fmt.Sprintf("Duplicate key '%v'", key))
+			}
+			out[key] = v
+		case map[string]interface{}:
+			if err = flatten(key, v, out); err != nil {
+				return err
+			}
+		default:
+			return errors.New(fmt.Sprintf("Value of key '%v' is not a string or object", key))
+		}
+	}
+
+	return nil
+}
+
+// Gets the key and plural category of a key with a plural suffix. ok is false if key has no suffix.
+func splitPluralKey(key string) (base, category string, ok bool) {
+	i := strings.LastIndex(key, "_")
+	if i < 1 {
+		return key, "", false
+	}
+	for _, c := range trans.AllPluralCategories {
+		if key[i+1:] == c {
+			return key[:i], c, true
+		}
+	}
+
+	return key, "", false
+}
+
+// Replaces groups of keys with plural suffixes by a single key whose value contains each of the
+// forms. A group must contain an 'other' form and at least one other form.
+func joinPlurals(flat map[string]string) {
+	groups := make(map[string]map[string]string)
+	for k, v := range flat {
+		if base, c, ok := splitPluralKey(k); ok {
+			if groups[base] == nil {
+				groups[base] = make(map[string]string)
+			}
+			groups[base][c] = v
+		}
+	}
+
+	for base, forms := range groups {
+		if _, ok := forms["other"]; !ok || len(forms) < 2 {
+			continue
+		}
+		if _, ok := flat[base]; ok {
+			continue
+		}
+
+		var values []string
+		for _, c := range trans.AllPluralCategories {
+			if v, ok := forms[c]; ok {
+				values = append(values, v)
+				delete(flat, base+"_"+c)
+			}
+		}
+		flat[base] = strings.Join(values, trans.PluralSeparator)
+	}
+}
+
+func getTranslation(s trans.String, l trans.Language) (t trans.Translation) {
+	if t, ok := s.Translations()[l]; ok {
+		return t
+	}
+
+	return nil
+}
+
+// Gets the keys and values that content should be written as. Content is split into plural forms
+// when the source text contains plural forms.
+func messages(name, sourceText, content string) map[string]string {
+	forms := trans.PluralForms(content)
+	suffixes := trans.PluralCategories(len(forms))
+	if len(trans.PluralForms(sourceText)) < 2 || suffixes == nil {
+		return map[string]string{name: content}
+	}
+
+	msgs := make(map[string]string)
+	for i, f := range forms {
+		msgs[name+"_"+suffixes[i]] = f
+	}
+
+	return msgs
+}
+
+// Adds a value to a nested object, creating objects for each part of the key as needed.
+func setNested(obj map[string]interface{}, key, value string) (err error) {
+	parts := strings.Split(key, keySeparator)
+	for _, p := range parts[:len(parts)-1] {
+		switch child := obj[p].(type) {
+		case nil:
+			next := make(map[string]interface{})
+			obj[p] = next
+			obj = next
+		case map[string]interface{}:
+			obj = child
+		default:
+			return errors.New(fmt.Sprintf("Cannot nest key '%v' as '%v' already has a value", key, p))
+		}
+	}
+
+	last := parts[len(parts)-1]
+	if _, ok := obj[last]; ok {
+		return errors.New(fmt.Sprintf("Cannot nest key '%v' as it clashes with another key", key))
+	}
+	obj[last] = value
+
+	return nil
+}
+
+// Exports the domain to a '[domain].[language].json' file for each language that it has
+// translations for. When nested is true, string names are split on '.' characters into nested
+// objects. When skipSource is true, no file is written for the source language.
+func Export(source trans.Domain, sourceLang trans.Language, dir string, nested, skipSource bool) (err error) {
+	// Create output directory
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	bundles := make(map[string]map[string]interface{})
+
+	for _, s := range source.Strings() {
+		sourceText := s.Name()
+		if sourceTrans := getTranslation(s, sourceLang); sourceTrans != nil {
+			sourceText = sourceTrans.Content()
+		}
+
+		for l, t := range s.Translations() {
+			if _, ok := bundles[l.Code]; !ok {
+				bundles[l.Code] = make(map[string]interface{})
+			}
+			bundle := bundles[l.Code]
+
+			for k, v := range messages(s.Name(), sourceText, t.Content()) {
+				if !nested {
+					bundle[k] = v
+					continue
+				}
+				if err = setNested(bundle, k, v); err != nil {
+					return errors.New(fmt.Sprintf("%v in domain '%v'", err, source.Name()))
+				}
+			}
+		}
+	}
+
+	for code, bundle := range bundles {
+		if skipSource && code == sourceLang.Code {
+			continue
+		}
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(bundle); err != nil {
+			return err
+		}
+
+		fileName := fmt.Sprintf("%v.%v.json", source.Name(), code)
+		err = ioutil.WriteFile(filepath.Join(dir, fileName), buf.Bytes(), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Removes previously exported JSON files from the given directory. Only files belonging to the
+// given domain and language are removed, where an empty domain or language matches any.
+func Remove(dir, domain, lang string) (err error) {
+	return trans.RemoveFiles(dir, "json", domain, lang)
+}
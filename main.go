@@ -10,11 +10,20 @@ The program must be run with a 'command' argument to indicate what you would lik
 Available commands are:
 
   - help: Prints usage instructions
-  - export: Exports all translations from the database to XLIFF files in the 'export_path' directory given in the config file.
-  - import: Imports translations from XLIFF files in the xliff 'import_path' given in the config file.
+  - export: Exports all translations from the database to files in the configured export format (e.g. XLIFF, PO, JSON or gotext) in the 'export_path' directory given in the config file.
+  - import: Imports translations from XLIFF, PO, JSON, YAML, .properties and ARB files in the xliff 'import_path' and 'import_paths' given in the config file, within a single transaction. The -dry-run flag reports the changes that would be made without making them, and -json prints them as JSON. The -import-policy flag selects how existing translations are treated, and -sync archives strings that are no longer in their domain's source language files.
   - init-db: Ensures that the database contains all necessary tables. Safe to be run multiple times.
+  - purge-archive: Permanently deletes strings that were archived longer ago than the 'retention_days' given in the config file.
   - remove-db: Removes all translation API data from the database (requires the --force flag).
-  - serve: Starts an HTTP server providing a JSON API for accessing and modifying the translation data.
+  - report: Prints how completely each domain is translated into each language, as a table or, with the -json or -csv flags, as JSON or CSV.
+  - serve: Starts an HTTP server providing a JSON API for accessing and modifying the translation data, authenticated with API tokens.
+  - sheet-export: Exports translations from the database to a CSV or XLSX spreadsheet for translators.
+  - sheet-import: Imports translations from a CSV or XLSX spreadsheet, listing the changed translations.
+  - tmx-export: Exports translations from the database to a TMX translation memory file.
+  - tmx-import: Imports translations from a TMX translation memory file into a domain.
+  - token-create: Creates an API token with a role, which requests to the HTTP server must be authenticated with. The -domains flag restricts a translator token to the given domains.
+  - token-delete: Deletes an API token.
+  - token-list: Lists the API tokens.
 */
 package main
 
@@ -31,12 +40,24 @@ import (
 var (
 	configPath string
 	force      bool
+	dryRun     bool
+	jsonOutput bool
+	csvOutput  bool
+	policy     string
+	sync       bool
+	domains    string
 )
 
 func init() {
 	defaultConfigPath := filepath.FromSlash("./translation-api.toml")
 	flag.StringVar(&configPath, "config", defaultConfigPath, "Full `path` and file name to the config file")
 	flag.BoolVar(&force, "force", false, "Use to allow potentially destructive changes")
+	flag.BoolVar(&dryRun, "dry-run", false, "Use with import to report the changes that would be made without making them")
+	flag.BoolVar(&jsonOutput, "json", false, "Use with import to print a JSON summary of the changes, or with report to print the report as JSON")
+	flag.BoolVar(&csvOutput, "csv", false, "Use with report to print the report as CSV")
+	flag.BoolVar(&sync, "sync", false, "Use with import to archive strings that are no longer in their domain's source language files")
+	flag.StringVar(&domains, "domains", "", "Use with token-create to restrict a translator token to a comma-separated list of domains")
+	flag.StringVar(&policy, "import-policy", "", "Use with import to override the config file's xliff.import_policy (overwrite, skip-existing, only-new-strings or fail-on-conflict)")
 }
 
 func checkFatal(err error) {
@@ -61,10 +82,28 @@ func parseArgs(args []string) (command string) {
 		return cmdImport
 	case cmdInitDb:
 		return cmdInitDb
+	case cmdPurgeArchive:
+		return cmdPurgeArchive
 	case cmdRemoveDb:
 		return cmdRemoveDb
+	case cmdReport:
+		return cmdReport
 	case cmdServe:
 		return cmdServe
+	case cmdSheetExport:
+		return cmdSheetExport
+	case cmdSheetImport:
+		return cmdSheetImport
+	case cmdTmxExport:
+		return cmdTmxExport
+	case cmdTmxImport:
+		return cmdTmxImport
+	case cmdTokenCreate:
+		return cmdTokenCreate
+	case cmdTokenDelete:
+		return cmdTokenDelete
+	case cmdTokenList:
+		return cmdTokenList
 	}
 
 	return cmdUnrecognised
@@ -84,9 +123,11 @@ func main() {
 	case cmdExport:
 		commandFunc = CommandFunc(export)
 	case cmdImport:
-		commandFunc = CommandFunc(importer.Import)
+		commandFunc = importWithOptions(importer.Options{DryRun: dryRun, Json: jsonOutput, Policy: policy, Sync: sync, Force: force})
 	case cmdInitDb:
 		commandFunc = CommandFunc(initDb)
+	case cmdPurgeArchive:
+		commandFunc = CommandFunc(purgeArchive)
 	case cmdRemoveDb:
 		// Force flag must be set to _really_ remove the database
 		if force {
@@ -94,8 +135,24 @@ func main() {
 		} else {
 			commandFunc = CommandFunc(printMustForceToRemoveDb)
 		}
+	case cmdReport:
+		commandFunc = reportWithOptions(jsonOutput, csvOutput)
 	case cmdServe:
 		commandFunc = CommandFunc(server.Serve)
+	case cmdSheetExport:
+		commandFunc = CommandFunc(sheetExport)
+	case cmdSheetImport:
+		commandFunc = CommandFunc(sheetImport)
+	case cmdTmxExport:
+		commandFunc = CommandFunc(tmxExport)
+	case cmdTmxImport:
+		commandFunc = CommandFunc(tmxImport)
+	case cmdTokenCreate:
+		commandFunc = tokenCreateWithDomains(domains)
+	case cmdTokenDelete:
+		commandFunc = CommandFunc(tokenDelete)
+	case cmdTokenList:
+		commandFunc = CommandFunc(tokenList)
 	}
 
 	// Invalid config only matters for non-'help' commands
@@ -0,0 +1,605 @@
+/*
+Package po implements reading and writing of GNU gettext PO and POT files.
+
+Strings are identified by their msgctxt when one is present, otherwise by their msgid. When
+exporting, the string's name is written as the msgctxt and the source language content as the
+msgid, so that gettext based applications can look up translations by their source text.
+
+Plural forms are mapped to translation content containing each of the forms separated by '|'
+characters, as used by Symfony's translator.
+
+Extracted (#.) and translator (#) comments are imported as the string's notes, and references (#:)
+as its references. When exporting, notes are written as extracted comments, and translations that
+are outdated or need review are flagged as fuzzy.
+*/
+package po
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gettext Plural-Forms headers of languages whose plural rules are not given by their number of forms
+// alone. The index of each form matches its position in translation content.
+var languagePluralForms = map[string]string{
+	"ar":    "nplurals=6; plural=(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5);",
+	"be":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"bs":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"cs":    "nplurals=3; plural=(n==1 ? 0 : n>=2 && n<=4 ? 1 : 2);",
+	"fr":    "nplurals=2; plural=(n > 1);",
+	"hr":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"id":    "nplurals=1; plural=0;",
+	"ja":    "nplurals=1; plural=0;",
+	"ko":    "nplurals=1; plural=0;",
+	"lt":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"pl":    "nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"pt-br": "nplurals=2; plural=(n > 1);",
+	"ro":    "nplurals=3; plural=(n==1 ? 0 : (n==0 || (n%100>0 && n%100<20)) ? 1 : 2);",
+	"ru":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"sk":    "nplurals=3; plural=(n==1 ? 0 : n>=2 && n<=4 ? 1 : 2);",
+	"sl":    "nplurals=4; plural=(n%100==1 ? 0 : n%100==2 ? 1 : n%100==3 || n%100==4 ? 2 : 3);",
+	"sr":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"th":    "nplurals=1; plural=0;",
+	"uk":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"vi":    "nplurals=1; plural=0;",
+	"zh":    "nplurals=1; plural=0;",
+}
+
+// Gets the gettext Plural-Forms header for the language with the given code, whose translations have
+// at most the given number of plural forms. Languages without a known rule get one that picks the
+// first form for 1 and the last form otherwise.
+func pluralFormsHeader(code string, forms int) string {
+	code = strings.ToLower(strings.Replace(code, "_", "-", -1))
+	if h, ok := languagePluralForms[code]; ok {
+		return h
+	}
+	if h, ok := languagePluralForms[strings.SplitN(code, "-", 2)[0]]; ok {
+		return h
+	}
+
+	if forms <= 2 {
+		return "nplurals=2; plural=(n != 1);"
+	}
+
+	return fmt.Sprintf("nplurals=%v; plural=(n==1 ? 0 : %v);", forms, forms-1)
+}
+
+type PoDomain struct {
+	name     string
+	Language string
+	Entries  []*PoEntry
+}
+
+func (pd PoDomain) Name() string {
+	return pd.name
+}
+func (pd *PoDomain) SetName(name string) {
+	pd.name = name
+}
+func (pd PoDomain) Strings() []trans.String {
+	ss := make([]trans.String, len(pd.Entries))
+	for i, e := range pd.Entries {
+		ss[i] = e
+	}
+
+	return ss
+}
+
+// A single message from a PO file
+type PoEntry struct {
+	language           *trans.Language
+	TranslatorComments []string
+	ExtractedComments  []string
+	References         []string
+	Flags              []string
+	Context            string
+	Id                 string
+	IdPlural           string
+	Str                []string
+}
+
+func (pe PoEntry) Name() string {
+	if pe.Context != "" {
+		return pe.Context
+	}
+
+	return pe.Id
+}
+
+// Translations gets the entry's translation. Fuzzy and untranslated entries have no translations.
+func (pe PoEntry) Translations() map[trans.Language]trans.Translation {
+	ts := make(map[trans.Language]trans.Translation)
+	if pe.Fuzzy() || pe.Content() == "" || pe.language == nil {
+		return ts
+	}
+	ts[*pe.language] = pe
+
+	return ts
+}
+func (pe PoEntry) Content() string {
+	if strings.Join(pe.Str, "") == "" {
+		return ""
+	}
+
+	return strings.Join(pe.Str, trans.PluralSeparator)
+}
+
+// Info gets the entry's extracted and translator comments as notes, along with its references.
+func (pe PoEntry) Info() trans.StringInfo {
+	info := trans.StringInfo{}
+	info.Notes = append(info.Notes, pe.ExtractedComments...)
+	info.Notes = append(info.Notes, pe.TranslatorComments...)
+	for _, r := range pe.References {
+		ref := trans.Reference{File: r}
+		if i := strings.LastIndex(r, ":"); i >= 0 {
+			if line, err := strconv.Atoi(r[i+1:]); err == nil {
+				ref = trans.Reference{File: r[:i], Line: line}
+			}
+		}
+		info.References = append(info.References, ref)
+	}
+
+	return info
+}
+
+// Fuzzy checks whether the entry has been flagged as needing review by a translator
+func (pe PoEntry) Fuzzy() bool {
+	for _, f := range pe.Flags {
+		if f == "fuzzy" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Creates a new PoDomain from the file at the given path
+func NewFromFile(file string) (pd *PoDomain, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	name, expectLang, err := trans.InfoFromFilename(filepath.Base(file))
+	if err != nil {
+		return nil, err
+	}
+
+	pd, err = Parse(f)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v in file '%v'", err, file))
+	}
+
+	// Header may use gettext style locale names, e.g. 'de_CH' for 'de-ch'
+	if pd.Language != "" && !strings.EqualFold(strings.Replace(pd.Language, "_", "-", -1), expectLang) {
+		return nil, errors.New(fmt.Sprintf(
+			"Found language '%v' but expected '%v' based on filename '%v' ",
+			pd.Language,
+			expectLang,
+			file))
+	}
+
+	pd.SetName(name)
+	pd.Language = expectLang
+
+	l := trans.Language{Code: expectLang}
+	for _, e := range pd.Entries {
+		e.language = &l
+	}
+
+	return pd, nil
+}
+
+// Parse reads PO data. The header entry is not included in the result's entries, but its Language
+// field is used to set the result's Language. Obsolete (#~) entries are ignored.
+func Parse(r io.Reader) (pd *PoDomain, err error) {
+	pd = &PoDomain{}
+
+	var (
+		e       = &PoEntry{}
+		started bool    // Whether e has any content yet
+		last    *string // The string that continuation lines are appended to
+		lineNum int
+	)
+
+	finishEntry := func() {
+		if !started {
+			return
+		}
+		if e.Id == "" && e.Context == "" {
+			if len(e.Str) > 0 {
+				pd.Language = headerField(e.Str[0], "Language")
+			}
+		} else {
+			pd.Entries = append(pd.Entries, e)
+		}
+		e = &PoEntry{}
+		started = false
+		last = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			finishEntry()
+			continue
+		case strings.HasPrefix(line, "#~"):
+			continue
+		case strings.HasPrefix(line, "#"):
+			// Comments belong to the next message, so end any message we are part way through
+			if last != nil {
+				finishEntry()
+			}
+			started = true
+			addComment(e, line)
+			continue
+		case strings.HasPrefix(line, `"`):
+			if last == nil {
+				return nil, errors.New(fmt.Sprintf("Unexpected string on line %v", lineNum))
+			}
+			s, err := unquote(line)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("%v on line %v", err, lineNum))
+			}
+			*last += s
+			continue
+		}
+
+		keyword, value := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			keyword, value = line[:i], strings.TrimSpace(line[i:])
+		}
+		s, err := unquote(value)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%v on line %v", err, lineNum))
+		}
+
+		switch {
+		case keyword == "msgctxt":
+			if last != nil {
+				finishEntry()
+			}
+			e.Context = s
+			last = &e.Context
+		case keyword == "msgid":
+			if last != nil && last != &e.Context {
+				finishEntry()
+			}
+			e.Id = s
+			last = &e.Id
+		case keyword == "msgid_plural":
+			e.IdPlural = s
+			last = &e.IdPlural
+		case keyword == "msgstr":
+			e.Str = []string{s}
+			last = &e.Str[0]
+		case strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+			n, err := strconv.Atoi(keyword[len("msgstr[") : len(keyword)-1])
+			if err != nil || n != len(e.Str) {
+				return nil, errors.New(fmt.Sprintf("Unexpected plural index '%v' on line %v", keyword, lineNum))
+			}
+			e.Str = append(e.Str, s)
+			last = &e.Str[n]
+		default:
+			return nil, errors.New(fmt.Sprintf("Unrecognised keyword '%v' on line %v", keyword, lineNum))
+		}
+		started = true
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	finishEntry()
+
+	return pd, nil
+}
+
+func addComment(e *PoEntry, line string) {
+	switch {
+	case strings.HasPrefix(line, "#."):
+		e.ExtractedComments = append(e.ExtractedComments, strings.TrimSpace(line[2:]))
+	case strings.HasPrefix(line, "#:"):
+		e.References = append(e.References, strings.Fields(line[2:])...)
+	case strings.HasPrefix(line, "#,"):
+		for _, f := range strings.Split(line[2:], ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				e.Flags = append(e.Flags, f)
+			}
+		}
+	case strings.HasPrefix(line, "#|"):
+		// Previous msgid of a fuzzy entry, not needed
+	default:
+		e.TranslatorComments = append(e.TranslatorComments, strings.TrimSpace(line[1:]))
+	}
+}
+
+// Gets the value of a field from a PO header entry's content
+func headerField(header, field string) string {
+	for _, line := range strings.Split(header, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == field {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+
+	return ""
+}
+
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", errors.New("Expected quoted string")
+	}
+	s = s[1 : len(s)-1]
+
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			out.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New("Unterminated escape sequence")
+		}
+		switch s[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		default:
+			// Includes \" and \\
+			out.WriteByte(s[i])
+		}
+	}
+
+	return out.String(), nil
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+	return `"` + r.Replace(s) + `"`
+}
+
+// Writes a keyword and its string value, splitting multi-line values after each newline
+func writeString(w *bufio.Writer, keyword, s string) {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 1 {
+		fmt.Fprintf(w, "%v %v\n", keyword, quote(s))
+		return
+	}
+
+	fmt.Fprintf(w, "%v \"\"\n", keyword)
+	for _, l := range lines {
+		fmt.Fprintln(w, quote(l))
+	}
+}
+
+// Write writes the domain in PO format, including a header entry. When the domain has a language, the
+// header includes the language's Plural-Forms, so that gettext can select each of its plural forms.
+func (pd *PoDomain) Write(out io.Writer) (err error) {
+	w := bufio.NewWriter(out)
+
+	header := "Project-Id-Version: go-translation-api\n" +
+		"MIME-Version: 1.0\n" +
+		"Content-Type: text/plain; charset=UTF-8\n" +
+		"Content-Transfer-Encoding: 8bit\n" +
+		fmt.Sprintf("X-Domain: %v\n", pd.name)
+	if pd.Language != "" {
+		forms := 0
+		for _, e := range pd.Entries {
+			if e.IdPlural != "" && len(e.Str) > forms {
+				forms = len(e.Str)
+			}
+		}
+
+		header += fmt.Sprintf("Language: %v\n", pd.Language)
+		header += fmt.Sprintf("Plural-Forms: %v\n", pluralFormsHeader(pd.Language, forms))
+	}
+	writeString(w, "msgid", "")
+	writeString(w, "msgstr", header)
+
+	for _, e := range pd.Entries {
+		fmt.Fprintln(w)
+		for _, c := range e.TranslatorComments {
+			fmt.Fprintf(w, "# %v\n", c)
+		}
+		for _, c := range e.ExtractedComments {
+			fmt.Fprintf(w, "#. %v\n", c)
+		}
+		for _, r := range e.References {
+			fmt.Fprintf(w, "#: %v\n", r)
+		}
+		if len(e.Flags) > 0 {
+			fmt.Fprintf(w, "#, %v\n", strings.Join(e.Flags, ", "))
+		}
+		if e.Context != "" {
+			writeString(w, "msgctxt", e.Context)
+		}
+		writeString(w, "msgid", e.Id)
+		if e.IdPlural == "" {
+			str := ""
+			if len(e.Str) > 0 {
+				str = e.Str[0]
+			}
+			writeString(w, "msgstr", str)
+			continue
+		}
+
+		writeString(w, "msgid_plural", e.IdPlural)
+		for i, str := range e.Str {
+			writeString(w, fmt.Sprintf("msgstr[%v]", i), str)
+		}
+	}
+
+	return w.Flush()
+}
+
+func getTranslation(s trans.String, l trans.Language) (t trans.Translation) {
+	if t, ok := s.Translations()[l]; ok {
+		return t
+	}
+
+	return nil
+}
+
+// Creates a PO entry for s with the given translation content, which may be empty. If the source
+// text contains exactly two plural forms, the entry will be a plural one. The string's notes are
+// written as extracted comments.
+func newEntry(s trans.String, sourceText, content string) *PoEntry {
+	e := &PoEntry{Id: sourceText}
+	if s.Name() != sourceText {
+		e.Context = s.Name()
+	}
+
+	info := trans.InfoOf(s)
+	e.ExtractedComments = info.Notes
+	for _, r := range info.References {
+		if r.Line > 0 {
+			e.References = append(e.References, fmt.Sprintf("%v:%v", r.File, r.Line))
+		} else {
+			e.References = append(e.References, r.File)
+		}
+	}
+
+	forms := trans.PluralForms(sourceText)
+	if len(forms) != 2 {
+		e.Str = []string{content}
+		return e
+	}
+
+	e.Id, e.IdPlural = forms[0], forms[1]
+	e.Str = trans.PluralForms(content)
+	for len(e.Str) < len(forms) {
+		e.Str = append(e.Str, "")
+	}
+
+	return e
+}
+
+// Exports the domain to a '[domain].[language].po' file for each language that it has translations
+// for, along with a '[domain].pot' template file. When skipSource is true, no file is written for
+// the source language.
+func Export(source trans.Domain, sourceLang trans.Language, dir string, skipSource bool) (err error) {
+	// Create output directory
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	template := &PoDomain{name: source.Name()}
+	pos := make(map[string]*PoDomain)
+	codes := make([]string, 0)
+
+	// Find all languages that need a file
+	for _, s := range source.Strings() {
+		for l := range s.Translations() {
+			if _, ok := pos[l.Code]; !ok {
+				pos[l.Code] = &PoDomain{name: source.Name(), Language: l.Code}
+				codes = append(codes, l.Code)
+			}
+		}
+	}
+	sort.Strings(codes)
+
+	for _, s := range source.Strings() {
+		// The message's source text, either the content in the source language, or the string name
+		// if content is not available
+		sourceText := s.Name()
+		if sourceTrans := getTranslation(s, sourceLang); sourceTrans != nil {
+			sourceText = sourceTrans.Content()
+		}
+
+		template.Entries = append(template.Entries, newEntry(s, sourceText, ""))
+
+		translations := make(map[string]trans.Translation)
+		for l, t := range s.Translations() {
+			translations[l.Code] = t
+		}
+		for _, code := range codes {
+			content := ""
+			t, ok := translations[code]
+			if ok {
+				content = t.Content()
+			}
+
+			e := newEntry(s, sourceText, content)
+			if ok && content != "" && trans.NeedsReview(t) {
+				e.Flags = append(e.Flags, "fuzzy")
+			}
+			pos[code].Entries = append(pos[code].Entries, e)
+		}
+	}
+
+	err = writeFile(filepath.Join(dir, fmt.Sprintf("%v.pot", source.Name())), template)
+	if err != nil {
+		return err
+	}
+	for _, code := range codes {
+		if skipSource && code == sourceLang.Code {
+			continue
+		}
+
+		err = writeFile(filepath.Join(dir, fmt.Sprintf("%v.%v.po", source.Name(), code)), pos[code])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFile(file string, pd *PoDomain) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return pd.Write(f)
+}
+
+// Removes previously exported PO and POT files from the given directory. Only files belonging to
+// the given domain and language are removed, where an empty domain or language matches any. Template
+// files are only removed when no language is given.
+func Remove(dir, domain, lang string) (err error) {
+	err = trans.RemoveFiles(dir, "po", domain, lang)
+	if err != nil || lang != "" {
+		return err
+	}
+
+	templates, err := filepath.Glob(filepath.Join(dir, "*.pot"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range templates {
+		if domain != "" && strings.TrimSuffix(filepath.Base(file), ".pot") != domain {
+			continue
+		}
+
+		if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,353 @@
+/*
+Package properties implements reading and writing of Java .properties resource bundles.
+
+Each file contains the translations of a single domain into a single language, and is named
+following the Java ResourceBundle convention of '[domain]_[locale].properties', e.g.
+'messages_de_CH.properties'. A file without a locale, e.g. 'messages.properties', is the base
+bundle and holds the source language.
+
+Files are written in ISO-8859-1, with any other characters written as '\uXXXX' escapes. When
+reading, files are decoded as UTF-8 if they are valid UTF-8, and as ISO-8859-1 otherwise, in the
+same way as Java's PropertyResourceBundle.
+*/
+package properties
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type PropertiesDomain struct {
+	name     string
+	Language string
+	Messages []*Property
+}
+
+func (pd PropertiesDomain) Name() string {
+	return pd.name
+}
+func (pd *PropertiesDomain) SetName(name string) {
+	pd.name = name
+}
+func (pd PropertiesDomain) Strings() []trans.String {
+	ss := make([]trans.String, len(pd.Messages))
+	for i, m := range pd.Messages {
+		ss[i] = m
+	}
+
+	return ss
+}
+
+type Property struct {
+	language *trans.Language
+	Key      string
+	Value    string
+}
+
+func (p Property) Name() string {
+	return p.Key
+}
+func (p Property) Translations() map[trans.Language]trans.Translation {
+	ts := make(map[trans.Language]trans.Translation)
+	ts[*p.language] = p
+
+	return ts
+}
+func (p Property) Content() string {
+	return p.Value
+}
+
+// Creates a new PropertiesDomain from the file at the given path. Base bundle files, which have no
+// locale in their name, are read as being in defaultLang.
+func NewFromFile(file, defaultLang string) (pd *PropertiesDomain, err error) {
+	name, lang, err := trans.InfoFromLocaleFilename(filepath.Base(file))
+	if err != nil {
+		return nil, err
+	}
+	if lang == "" {
+		lang = defaultLang
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	props, err := Parse(f)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v in file '%v'", err, file))
+	}
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	l := trans.Language{Code: lang}
+	pd = &PropertiesDomain{name: name, Language: lang}
+	for _, k := range keys {
+		pd.Messages = append(pd.Messages, &Property{language: &l, Key: k, Value: props[k]})
+	}
+
+	return pd, nil
+}
+
+// Parse reads the keys and values from .properties file content. Where a key appears more than
+// once, the last value is used.
+func Parse(r io.Reader) (props map[string]string, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(data)
+	if !utf8.Valid(data) {
+		text = decodeLatin1(data)
+	}
+
+	props = make(map[string]string)
+	for i, line := range logicalLines(text) {
+		key, value := splitLine(line)
+
+		k, err := unescape(key)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%v in entry %v", err, i+1))
+		}
+		v, err := unescape(value)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%v in entry %v", err, i+1))
+		}
+		props[k] = v
+	}
+
+	return props, nil
+}
+
+func decodeLatin1(data []byte) string {
+	rs := make([]rune, len(data))
+	for i, b := range data {
+		rs[i] = rune(b)
+	}
+
+	return string(rs)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\f'
+}
+
+// Joins continued lines and removes comments and blank lines, giving one line per entry
+func logicalLines(text string) (lines []string) {
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+
+	var current strings.Builder
+	continuing := false
+	for _, natural := range strings.Split(text, "\n") {
+		natural = strings.TrimLeft(natural, " \t\f")
+		if !continuing && (natural == "" || natural[0] == '#' || natural[0] == '!') {
+			continue
+		}
+
+		// An odd number of trailing backslashes means the line continues onto the next
+		slashes := len(natural) - len(strings.TrimRight(natural, `\`))
+		continuing = slashes%2 == 1
+		if continuing {
+			natural = natural[:len(natural)-1]
+		}
+
+		current.WriteString(natural)
+		if !continuing {
+			lines = append(lines, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+
+	return lines
+}
+
+// Splits a logical line into its (still escaped) key and value
+func splitLine(line string) (key, value string) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' || isSpace(c) {
+			end = i
+			break
+		}
+	}
+	key = line[:end]
+
+	i := end
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	if i < len(line) && (line[i] == '=' || line[i] == ':') {
+		i++
+	}
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+
+	return key, line[i:]
+}
+
+// Replaces escape sequences, combining any '\uXXXX' escaped UTF-16 surrogate pairs
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var units []rune
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if r != '\\' {
+			units = append(units, r)
+			continue
+		}
+		if i >= len(s) {
+			break
+		}
+
+		r, size = utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch r {
+		case 't':
+			r = '\t'
+		case 'n':
+			r = '\n'
+		case 'r':
+			r = '\r'
+		case 'f':
+			r = '\f'
+		case 'u':
+			if i+4 > len(s) {
+				return "", errors.New("Malformed \\uXXXX escape")
+			}
+			n, err := strconv.ParseUint(s[i:i+4], 16, 16)
+			if err != nil {
+				return "", errors.New(fmt.Sprintf("Malformed \\uXXXX escape '\\u%v'", s[i:i+4]))
+			}
+			r = rune(n)
+			i += 4
+		}
+		units = append(units, r)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(units); i++ {
+		if utf16.IsSurrogate(units[i]) && i+1 < len(units) {
+			if r := utf16.DecodeRune(units[i], units[i+1]); r != utf8.RuneError {
+				b.WriteRune(r)
+				i++
+				continue
+			}
+		}
+		b.WriteRune(units[i])
+	}
+
+	return b.String(), nil
+}
+
+// Escapes a key or value for writing to an ISO-8859-1 .properties file
+func escape(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '=' || r == ':' || r == '#' || r == '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04X`, u)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// Exports the domain to a '[domain]_[locale].properties' file for each language that it has
+// translations for. The source language is also written to the '[domain].properties' base bundle.
+// When skipSource is true, neither file is written for the source language.
+func Export(source trans.Domain, sourceLang trans.Language, dir string, skipSource bool) (err error) {
+	// Create output directory
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string]*bytes.Buffer)
+
+	strs := append([]trans.String(nil), source.Strings()...)
+	sort.Slice(strs, func(i, j int) bool { return strs[i].Name() < strs[j].Name() })
+
+	for _, s := range strs {
+		for l, t := range s.Translations() {
+			if _, ok := files[l.Code]; !ok {
+				files[l.Code] = &bytes.Buffer{}
+			}
+			fmt.Fprintf(files[l.Code], "%v=%v\n", escape(s.Name(), true), escape(t.Content(), false))
+		}
+	}
+
+	for code, buf := range files {
+		if skipSource && code == sourceLang.Code {
+			continue
+		}
+
+		fileNames := []string{fmt.Sprintf("%v_%v.properties", source.Name(), trans.UnderscoreLocale(code))}
+		if code == sourceLang.Code {
+			fileNames = append(fileNames, source.Name()+".properties")
+		}
+
+		for _, fileName := range fileNames {
+			err = ioutil.WriteFile(filepath.Join(dir, fileName), buf.Bytes(), 0644)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Removes previously exported .properties files from the given directory. Only files belonging to
+// the given domain and language are removed, where an empty domain or language matches any.
+func Remove(dir, domain, lang string) (err error) {
+	return trans.RemoveLocaleFiles(dir, "properties", domain, lang)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -10,15 +11,35 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/toolani/go-translation-api/config"
 	"github.com/toolani/go-translation-api/datastore"
+	"github.com/toolani/go-translation-api/format"
+	"github.com/toolani/go-translation-api/trans"
+	"github.com/toolani/go-translation-api/xliff"
+	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 )
 
 var (
-	export    chan string
-	exportDir string
+	export         chan exportJob
+	exportDir      string
+	exportConfig   config.ExportConfig
+	sourceLanguage string
 )
 
+// An exportJob tells the background export goroutine which domain's files need to be updated.
+type exportJob struct {
+	// Name of the domain to export. May be empty if nothing needs exporting.
+	domain string
+	// Name of a domain whose previously exported files should be removed, e.g. after it was renamed
+	// or deleted. May be empty.
+	stale string
+	// Code of a language whose previously exported files should be removed from every domain, e.g.
+	// after it was deleted or its code was changed. May be empty.
+	staleLanguage string
+}
+
 func checkFatal(err error) {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err)
@@ -49,6 +70,15 @@ func checkHttpWithStatus(e error, w http.ResponseWriter, status int) (hadError b
 	return false
 }
 
+// errInvalidDomainName is returned when a domain name could not be used in an exported file name.
+var errInvalidDomainName = errors.New("Domain name must not be empty or contain '/' or '\\' characters")
+
+// validDomainName checks that a domain name can be used to build a '[domain].[language].xliff' file
+// name that can later be imported again. Names may contain '.' characters, as imported ones can.
+func validDomainName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "/\\")
+}
+
 func checkHttp(e error, w http.ResponseWriter) (hadError bool) {
 	status := http.StatusInternalServerError
 	if e == sql.ErrNoRows {
@@ -65,10 +95,144 @@ func handleWithDatastore(db *sqlx.DB, driver string, f func(http.ResponseWriter,
 		if checkHttpWithStatus(err, w, http.StatusServiceUnavailable) {
 			return
 		}
+		ds.SourceLanguage = sourceLanguage
+		ds.RevisionSource = datastore.RevisionSourceApi
+		if t, ok := requestToken(r); ok {
+			ds.RevisionAuthor = t.Name
+		}
+		f(w, r, ds)
+	}
+}
+
+// Key of the authenticated API token in a request's context
+type tokenKey struct{}
+
+// Gets the API token that a request was authenticated with
+func requestToken(r *http.Request) (t datastore.Token, ok bool) {
+	t, ok = r.Context().Value(tokenKey{}).(datastore.Token)
+	return t, ok
+}
+
+// Gets the secret of the API token sent with a request, either as a bearer token in its
+// 'Authorization' header or in its 'X-Api-Key' header
+func requestSecret(r *http.Request) string {
+	if key := r.Header.Get("X-Api-Key"); key != "" {
+		return key
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+
+	return ""
+}
+
+// Middleware that rejects requests that do not have a valid API token, adding the token to the
+// context of those that do
+func authenticate(db *sqlx.DB, driver string) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret := requestSecret(r)
+			if secret == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				checkHttpWithStatus(errors.New("An API token is required"), w, http.StatusUnauthorized)
+				return
+			}
+
+			ds, err := datastore.New(db, driver)
+			if checkHttpWithStatus(err, w, http.StatusServiceUnavailable) {
+				return
+			}
+
+			t, err := ds.Authenticate(secret)
+			if err == sql.ErrNoRows {
+				w.Header().Set("WWW-Authenticate", "Bearer error=\"invalid_token\"")
+				checkHttpWithStatus(errors.New("Invalid API token"), w, http.StatusUnauthorized)
+				return
+			}
+			if checkHttp(err, w) {
+				return
+			}
+
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenKey{}, t)))
+		})
+	}
+}
+
+// Wraps a handler so that it is only run for requests whose API token has at least the given role.
+// Translator tokens only have the translator role for their own languages, and may only access the
+// domains that they are restricted to, going by the language and domain in the request's path.
+func authorize(role string, f func(http.ResponseWriter, *http.Request, *datastore.DataStore)) func(http.ResponseWriter, *http.Request, *datastore.DataStore) {
+	return func(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+		t, ok := requestToken(r)
+		lang := mux.Vars(r)["lang"]
+		// Routes under '/domains/{name}' name their domain 'name' rather than 'domain'
+		domain := mux.Vars(r)["domain"]
+		if domain == "" {
+			domain = mux.Vars(r)["name"]
+		}
+
+		switch {
+		case !ok:
+			checkHttpWithStatus(errors.New("An API token is required"), w, http.StatusUnauthorized)
+			return
+
+		case domain != "" && !t.AllowsDomain(domain):
+			checkHttpWithStatus(errors.New(fmt.Sprintf("API token '%v' may not access domain '%v'", t.Name, domain)), w, http.StatusForbidden)
+			return
+
+		case t.Role == datastore.RoleTranslator && role == datastore.RoleTranslator && !t.Allows(role, lang):
+			checkHttpWithStatus(errors.New(fmt.Sprintf("API token '%v' may not change translations into '%v'", t.Name, lang)), w, http.StatusForbidden)
+			return
+
+		case !t.Allows(role, lang):
+			checkHttpWithStatus(errors.New(fmt.Sprintf("API token '%v' does not have the %v role needed for this request", t.Name, role)), w, http.StatusForbidden)
+			return
+		}
+
 		f(w, r, ds)
 	}
 }
 
+// translationFilter decides which translations the request's API token may see. Translator tokens
+// may only see the domains that they are restricted to, and only the translations into their own
+// languages and into the source language of each domain.
+type translationFilter struct {
+	token datastore.Token
+	ds    *datastore.DataStore
+	// Source language code of each domain that has been looked up
+	sources map[string]string
+}
+
+func newTranslationFilter(r *http.Request, ds *datastore.DataStore) *translationFilter {
+	t, _ := requestToken(r)
+	return &translationFilter{token: t, ds: ds, sources: make(map[string]string)}
+}
+
+// Checks whether the token may see the translations of the named domain into the language with the
+// given code
+func (f *translationFilter) allows(domain, code string) (ok bool, err error) {
+	if !f.token.AllowsDomain(domain) {
+		return false, nil
+	}
+	if f.token.AllowsLanguage(code) {
+		return true, nil
+	}
+
+	src, ok := f.sources[domain]
+	if !ok {
+		l, err := f.ds.GetSourceLanguage(domain)
+		if err != nil {
+			return false, err
+		}
+		src = l.Code
+		f.sources[domain] = src
+	}
+
+	return code == src, nil
+}
+
 func setJsonHeaders(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -115,26 +279,130 @@ func createLanguageHandler(w http.ResponseWriter, r *http.Request, ds *datastore
 	w.Write([]byte("{\"result\":\"ok\"}\n"))
 }
 
-// Gets list of available translation domain names
+// Gets a single language along with statistics about its usage in each domain. Only the domains that
+// the request's API token may access are included.
+func getLanguageHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	code := mux.Vars(r)["lang"]
+
+	stats, err := ds.GetLanguageStats(code)
+	if checkHttp(err, w) {
+		return
+	}
+
+	t, _ := requestToken(r)
+	allowed := make([]datastore.DomainLanguageStats, 0, len(stats.Domains))
+	for _, d := range stats.Domains {
+		if t.AllowsDomain(d.DomainName) {
+			allowed = append(allowed, d)
+		}
+	}
+	stats.Domains = allowed
+
+	enc := json.NewEncoder(w)
+	checkHttp(enc.Encode(stats), w)
+}
+
+// Updates a language's name and, optionally, its code.
+// If the code was changed, all domains are re-exported so that their files use the new code.
+func updateLanguageHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	code := mux.Vars(r)["lang"]
+
+	var content struct {
+		Code string `json:"code"`
+		Name string `json:"name"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&content)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not decode request (%v)", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if content.Name == "" {
+		checkHttpWithStatus(errors.New("A 'name' property is required"), w, http.StatusBadRequest)
+		return
+	}
+	if content.Code == "" {
+		content.Code = code
+	}
+
+	err = ds.UpdateLanguage(code, content.Code, content.Name)
+	switch {
+	case err == datastore.ErrAlreadyExists:
+		_ = checkHttpWithStatus(err, w, http.StatusConflict)
+		return
+
+	case checkHttp(err, w):
+		return
+	}
+
+	var domains []trans.Domain
+	if content.Code != code {
+		domains, err = ds.GetDomainList()
+		if checkHttp(err, w) {
+			return
+		}
+	}
+
+	w.Write([]byte("{\"result\":\"ok\"}\n"))
+
+	if content.Code != code {
+		export <- exportJob{staleLanguage: code}
+		for _, dom := range domains {
+			export <- exportJob{domain: dom.Name()}
+		}
+	}
+}
+
+// Deletes a language. Languages that still have translations are only deleted, along with their
+// translations, if the 'force' query parameter is "true".
+// On success, the language's exported files will be removed.
+func deleteLanguageHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	code := mux.Vars(r)["lang"]
+	force := r.URL.Query().Get("force") == "true"
+
+	err := ds.DeleteLanguage(code, force)
+	switch {
+	case err == datastore.ErrInUse:
+		_ = checkHttpWithStatus(errors.New("Language still has translations, use 'force=true' to delete them too"), w, http.StatusConflict)
+		return
+
+	case checkHttp(err, w):
+		return
+	}
+
+	w.Write([]byte("{\"result\":\"ok\"}\n"))
+
+	export <- exportJob{staleLanguage: code}
+}
+
+// Gets list of available translation domain names, leaving out those that the request's API token
+// may not access
 func getDomainsHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
 	doms, err := ds.GetDomainList()
 	if checkHttp(err, w) {
 		return
 	}
 
+	t, _ := requestToken(r)
+
 	var output struct {
 		Domains []string `json:"domains"`
 	}
-	output.Domains = make([]string, len(doms))
-	for i, d := range doms {
-		output.Domains[i] = d.Name()
+	output.Domains = make([]string, 0, len(doms))
+	for _, d := range doms {
+		if t.AllowsDomain(d.Name()) {
+			output.Domains = append(output.Domains, d.Name())
+		}
 	}
 
 	enc := json.NewEncoder(w)
 	checkHttp(enc.Encode(output), w)
 }
 
-// Get a domain and all its strings & translations
+// Get a domain and all its strings & translations. For translator tokens, only the translations into
+// their own languages and the domain's source language are included.
 func getDomainHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
 	name := mux.Vars(r)["name"]
 
@@ -143,33 +411,205 @@ func getDomainHandler(w http.ResponseWriter, r *http.Request, ds *datastore.Data
 		return
 	}
 
+	src, err := ds.GetSourceLanguage(name)
+	if checkHttp(err, w) {
+		return
+	}
+
+	out := NewDomain(dom)
+	out.SourceLanguage = src.Code
+
+	t, _ := requestToken(r)
+	out.keepLanguages(func(code string) bool {
+		return code == src.Code || t.AllowsLanguage(code)
+	})
+
 	enc := json.NewEncoder(w)
-	checkHttp(enc.Encode(NewDomain(dom)), w)
+	checkHttp(enc.Encode(out), w)
 }
 
-// Export a domain to XLIFF files on disk
-func exportDomainHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+// Creates a new, empty domain.
+// The request body is optional. If given, its 'source_language' property sets the language that the
+// domain's strings are translated from.
+func createDomainHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	name := mux.Vars(r)["name"]
+
+	var content struct {
+		SourceLanguage string `json:"source_language"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&content)
+	if err != nil && err != io.EOF {
+		http.Error(w, fmt.Sprintf("Could not decode request (%v)", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if !validDomainName(name) {
+		checkHttpWithStatus(errInvalidDomainName, w, http.StatusBadRequest)
+		return
+	}
+
+	_, err = ds.CreateDomain(name)
+	switch {
+	case err == datastore.ErrAlreadyExists:
+		_ = checkHttpWithStatus(err, w, http.StatusConflict)
+		return
+
+	case checkHttp(err, w):
+		return
+	}
+
+	if content.SourceLanguage != "" {
+		err = ds.SetSourceLanguage(name, content.SourceLanguage)
+		if checkHttp(err, w) {
+			return
+		}
+	}
+
+	w.Write([]byte("{\"result\":\"ok\"}\n"))
+}
+
+// Updates a domain's name and/or source language.
+// On success, the domain is re-exported to file. If it was renamed, any files exported under its old
+// name are removed.
+func updateDomainHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
 	name := mux.Vars(r)["name"]
 
-	err := ds.ExportDomain(name, exportDir)
+	// Properties that are missing from the request are left unchanged. An empty 'source_language'
+	// removes the domain's own source language.
+	var content struct {
+		Name           *string `json:"name"`
+		SourceLanguage *string `json:"source_language"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&content)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not decode request (%v)", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	job := exportJob{domain: name}
+
+	if content.Name != nil && *content.Name != name {
+		if !validDomainName(*content.Name) {
+			checkHttpWithStatus(errInvalidDomainName, w, http.StatusBadRequest)
+			return
+		}
+
+		err = ds.RenameDomain(name, *content.Name)
+		switch {
+		case err == datastore.ErrAlreadyExists:
+			_ = checkHttpWithStatus(err, w, http.StatusConflict)
+			return
+
+		case checkHttp(err, w):
+			return
+		}
+
+		job = exportJob{domain: *content.Name, stale: name}
+	}
+
+	if content.SourceLanguage != nil {
+		err = ds.SetSourceLanguage(job.domain, *content.SourceLanguage)
+		if checkHttp(err, w) {
+			return
+		}
+	}
+
+	w.Write([]byte("{\"result\":\"ok\"}\n"))
+
+	export <- job
+}
+
+// Deletes a domain and all of its strings and translations.
+// On success, the domain's exported files will be removed.
+func deleteDomainHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	name := mux.Vars(r)["name"]
+
+	err := ds.DeleteDomain(name)
 	if checkHttp(err, w) {
 		return
 	}
 
 	w.Write([]byte("{\"result\":\"ok\"}\n"))
+
+	export <- exportJob{stale: name}
+}
+
+// Gets the export targets to use for a request. These are the configured export targets, unless
+// the 'format' query parameter is given, in which case a single target is used that exports all
+// domains and languages in that format to the xliff.export_path. The 'xliff_version' query
+// parameter, if given, replaces the XLIFF version of every target.
+func requestedExportTargets(r *http.Request) (targets []config.ExportTarget, err error) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		targets = append(targets, exportConfig.Targets...)
+	} else if f, ok := format.Get(name); !ok || f.Writer == nil {
+		return nil, errors.New(fmt.Sprintf("Unrecognised value for 'format' parameter. Must be one of: %v", strings.Join(format.WritableNames(), ", ")))
+	} else {
+		targets = []config.ExportTarget{{
+			Format:       name,
+			Path:         exportDir,
+			JsonStyle:    exportConfig.JsonStyle,
+			XliffVersion: exportConfig.XliffVersion,
+			GoCatalog:    exportConfig.GoCatalog,
+			ApprovedOnly: exportConfig.ApprovedOnly,
+		}}
+	}
+
+	if version := r.URL.Query().Get("xliff_version"); version != "" {
+		if !xliff.ValidVersion(version) {
+			return nil, errors.New(fmt.Sprintf("Unrecognised value for 'xliff_version' parameter. Must be one of: %v", strings.Join(xliff.Versions, ", ")))
+		}
+		for i := range targets {
+			targets[i].XliffVersion = version
+		}
+	}
+
+	return targets, nil
+}
+
+// Export a domain to files on disk
+// Accepts optional 'format' and 'xliff_version' query parameters, see requestedExportTargets.
+func exportDomainHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	name := mux.Vars(r)["name"]
+
+	targets, err := requestedExportTargets(r)
+	if checkHttpWithStatus(err, w, http.StatusBadRequest) {
+		return
+	}
+
+	for _, t := range targets {
+		err = ds.ExportDomain(name, t)
+		if checkHttp(err, w) {
+			return
+		}
+	}
+
+	w.Write([]byte("{\"result\":\"ok\"}\n"))
 }
 
-// Exports all domains to XLIFF files on disk
+// Exports all domains to files on disk
+// Accepts optional 'format' and 'xliff_version' query parameters, see requestedExportTargets.
 func exportAllDomainsHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	targets, err := requestedExportTargets(r)
+	if checkHttpWithStatus(err, w, http.StatusBadRequest) {
+		return
+	}
+
 	domains, err := ds.GetDomainList()
 	if checkHttp(err, w) {
 		return
 	}
 
-	for _, dom := range domains {
-		err = ds.ExportDomain(dom.Name(), exportDir)
-		if checkHttp(err, w) {
-			return
+	for _, t := range targets {
+		for _, dom := range domains {
+			err = ds.ExportDomain(dom.Name(), t)
+			if checkHttp(err, w) {
+				return
+			}
 		}
 	}
 
@@ -206,23 +646,94 @@ func createOrUpdateTranslationHandler(w http.ResponseWriter, r *http.Request, ds
 
 	w.Write([]byte("{\"result\":\"ok\"}\n"))
 
-	export <- dName
+	export <- exportJob{domain: dName}
 }
 
-// Deletes a single string and all its associated translations.
+// Archives a single string, which removes it and its translations from the domain until it is
+// restored.
 // On success, the affected domain will be re-exported to file.
 func deleteStringHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
 	dName := mux.Vars(r)["domain"]
 	sName := mux.Vars(r)["string"]
 
-	err := ds.DeleteString(dName, sName)
+	err := ds.ArchiveString(dName, sName)
 	if checkHttp(err, w) {
 		return
 	}
 
 	w.Write([]byte("{\"result\":\"ok\"}\n"))
 
-	export <- dName
+	export <- exportJob{domain: dName}
+}
+
+// Restores an archived string along with its translations.
+// On success, the affected domain will be re-exported to file.
+func restoreStringHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	dName := mux.Vars(r)["domain"]
+	sName := mux.Vars(r)["string"]
+
+	err := ds.RestoreString(dName, sName)
+	if checkHttp(err, w) {
+		return
+	}
+
+	w.Write([]byte("{\"result\":\"ok\"}\n"))
+
+	export <- exportJob{domain: dName}
+}
+
+// Gets the archived strings of a domain and their translations
+func getArchivedStringsHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	name := mux.Vars(r)["name"]
+
+	strs, err := ds.GetArchivedStrings(name)
+	if checkHttp(err, w) {
+		return
+	}
+
+	src, err := ds.GetSourceLanguage(name)
+	if checkHttp(err, w) {
+		return
+	}
+
+	out := NewArchivedDomain(name, strs)
+
+	t, _ := requestToken(r)
+	out.keepLanguages(func(code string) bool {
+		return code == src.Code || t.AllowsLanguage(code)
+	})
+
+	enc := json.NewEncoder(w)
+	checkHttp(enc.Encode(out), w)
+}
+
+// Gets the names of the strings of a domain that have no translation into the language given by the
+// required 'lang' query parameter
+func getMissingStringsHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	name := mux.Vars(r)["name"]
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		checkHttpWithStatus(errors.New("A 'lang' query parameter is required"), w, http.StatusBadRequest)
+		return
+	}
+
+	ok, err := newTranslationFilter(r, ds).allows(name, lang)
+	if checkHttp(err, w) {
+		return
+	}
+	if !ok {
+		t, _ := requestToken(r)
+		checkHttpWithStatus(errors.New(fmt.Sprintf("API token '%v' may not see translations into '%v'", t.Name, lang)), w, http.StatusForbidden)
+		return
+	}
+
+	strs, err := ds.GetMissingStrings(name, lang)
+	if checkHttp(err, w) {
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	checkHttp(enc.Encode(MissingStrings{Name: name, Language: lang, Strings: strs}), w)
 }
 
 // Delete a single translation.
@@ -239,7 +750,105 @@ func deleteTranslationHandler(w http.ResponseWriter, r *http.Request, ds *datast
 
 	w.Write([]byte("{\"result\":\"ok\"}\n"))
 
-	export <- dName
+	export <- exportJob{domain: dName}
+}
+
+// Changes the workflow status of a translation. Only admin tokens may approve or reject translations.
+// On success, the affected domain will be re-exported to file, as it may be exported with only its
+// approved translations.
+func setTranslationStatusHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	dName := mux.Vars(r)["domain"]
+	sName := mux.Vars(r)["string"]
+	lang := mux.Vars(r)["lang"]
+
+	var content struct {
+		Status string `json:"status"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&content)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not decode request (%v)", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if !datastore.ValidStatus(content.Status) {
+		checkHttpWithStatus(errors.New(fmt.Sprintf("Unrecognised value for 'status' property. Must be one of: %v", strings.Join(datastore.Statuses, ", "))), w, http.StatusBadRequest)
+		return
+	}
+
+	t, _ := requestToken(r)
+	if (content.Status == datastore.StatusApproved || content.Status == datastore.StatusRejected) && !t.Allows(datastore.RoleAdmin, lang) {
+		checkHttpWithStatus(errors.New(fmt.Sprintf("API token '%v' does not have the %v role needed to approve or reject translations", t.Name, datastore.RoleAdmin)), w, http.StatusForbidden)
+		return
+	}
+
+	err = ds.SetTranslationStatus(dName, sName, lang, content.Status)
+	switch {
+	case err == datastore.ErrInvalidTransition:
+		_ = checkHttpWithStatus(err, w, http.StatusConflict)
+		return
+
+	case checkHttp(err, w):
+		return
+	}
+
+	w.Write([]byte("{\"result\":\"ok\"}\n"))
+
+	export <- exportJob{domain: dName}
+}
+
+// Gets the revisions of a translation, newest first
+func getTranslationHistoryHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	dName := mux.Vars(r)["domain"]
+	sName := mux.Vars(r)["string"]
+	lang := mux.Vars(r)["lang"]
+
+	ok, err := newTranslationFilter(r, ds).allows(dName, lang)
+	if checkHttp(err, w) {
+		return
+	}
+	if !ok {
+		t, _ := requestToken(r)
+		checkHttpWithStatus(errors.New(fmt.Sprintf("API token '%v' may not see translations into '%v'", t.Name, lang)), w, http.StatusForbidden)
+		return
+	}
+
+	revs, err := ds.GetTranslationHistory(dName, sName, lang)
+	if checkHttp(err, w) {
+		return
+	}
+
+	var output struct {
+		Revisions []datastore.Revision `json:"revisions"`
+	}
+	output.Revisions = revs
+
+	enc := json.NewEncoder(w)
+	checkHttp(enc.Encode(output), w)
+}
+
+// Sets a translation's content back to that of one of its revisions.
+// On success, the affected domain will be re-exported to file.
+func revertTranslationHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	dName := mux.Vars(r)["domain"]
+	sName := mux.Vars(r)["string"]
+	lang := mux.Vars(r)["lang"]
+
+	revId, err := strconv.ParseInt(mux.Vars(r)["revision"], 10, 64)
+	if err != nil {
+		checkHttpWithStatus(errors.New("Revision must be a number"), w, http.StatusBadRequest)
+		return
+	}
+
+	err = ds.RevertTranslation(dName, sName, lang, revId)
+	if checkHttp(err, w) {
+		return
+	}
+
+	w.Write([]byte("{\"result\":\"ok\"}\n"))
+
+	export <- exportJob{domain: dName}
 }
 
 const (
@@ -248,10 +857,57 @@ const (
 	searchByTranslationContent = "translation_content"
 )
 
+// Lists the translations that are flagged as outdated, filtered by the optional 'lang' query parameter
+func getOutdatedTranslationsHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	res, err := ds.GetOutdatedTranslations(r.URL.Query().Get("lang"))
+	if checkHttp(err, w) {
+		return
+	}
+
+	filter := newTranslationFilter(r, ds)
+	allowed := make([]datastore.OutdatedTranslation, 0, len(res))
+	for _, ot := range res {
+		ok, err := filter.allows(ot.DomainName, ot.LanguageCode)
+		if checkHttp(err, w) {
+			return
+		}
+		if ok {
+			allowed = append(allowed, ot)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	checkHttp(enc.Encode(allowed), w)
+}
+
+// Gets the translation stats of each domain for each language, including those without translations
+func getTranslationStatsHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
+	stats, err := ds.GetTranslationStats()
+	if checkHttp(err, w) {
+		return
+	}
+
+	filter := newTranslationFilter(r, ds)
+	allowed := make([]datastore.TranslationStats, 0, len(stats))
+	for _, st := range stats {
+		ok, err := filter.allows(st.DomainName, st.LanguageCode)
+		if checkHttp(err, w) {
+			return
+		}
+		if ok {
+			allowed = append(allowed, st)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	checkHttp(enc.Encode(allowed), w)
+}
+
 // Search for translations
 // Accepts 'term' and 'by' query parameters. 'term' is required and is the text to search for. 'by'
 // is optional and controls which field is used for searching. 'by' may be one of "all",
 // "string_name" or "translation_content"
+// Results are left out in the same way as getDomainHandler leaves out domains and translations.
 func searchHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataStore) {
 	term := r.URL.Query().Get("term")
 	if term == "" {
@@ -284,43 +940,91 @@ func searchHandler(w http.ResponseWriter, r *http.Request, ds *datastore.DataSto
 		return
 	}
 
+	filter := newTranslationFilter(r, ds)
+	allowed := make([]datastore.SearchResult, 0, len(res))
+	for _, sr := range res {
+		ok, err := filter.allows(sr.DomainName, sr.LanguageCode)
+		if checkHttp(err, w) {
+			return
+		}
+		if ok {
+			allowed = append(allowed, sr)
+		}
+	}
+
 	enc := json.NewEncoder(w)
-	checkHttp(enc.Encode(res), w)
+	checkHttp(enc.Encode(allowed), w)
 }
 
 func Serve(c config.Config) {
 	exportDir = c.XLIFF.ExportPath
-	export = make(chan string, 100)
+	exportConfig = c.Export
+	sourceLanguage = c.XLIFF.SourceLanguage
+	export = make(chan exportJob, 100)
 
 	var db *sqlx.DB
 	db, err := sqlx.Connect(c.DB.Driver, c.DB.ConnectionString())
 	checkFatal(err)
 
+	ds, err := datastore.New(db, c.DB.Driver)
+	checkFatal(err)
+	tokens, err := ds.GetTokenList()
+	checkFatal(err)
+	if len(tokens) == 0 {
+		fmt.Fprintln(os.Stderr, "Warning: no API tokens exist, so every request will be refused. Create one with the token-create command.")
+	}
+
 	// Listen for domains to export to file
 	go func() {
 		ds, err := datastore.New(db, c.DB.Driver)
 		checkFatal(err)
+		ds.SourceLanguage = c.XLIFF.SourceLanguage
 
 		for {
-			d := <-export
-			err := ds.ExportDomain(d, c.XLIFF.ExportPath)
-			if err != nil {
-				fmt.Println(err)
+			job := <-export
+			for _, t := range c.Export.Targets {
+				if job.stale != "" || job.staleLanguage != "" {
+					err := ds.RemoveExportedFiles(t, job.stale, job.staleLanguage)
+					if err != nil {
+						fmt.Println(err)
+					}
+				}
+				if job.domain != "" {
+					err := ds.ExportDomain(job.domain, t)
+					if err != nil {
+						fmt.Println(err)
+					}
+				}
 			}
 		}
 	}()
 
 	r := mux.NewRouter().StrictSlash(true)
-	r.HandleFunc("/domains", handleWithDatastore(db, c.DB.Driver, getDomainsHandler)).Methods("GET")
-	r.HandleFunc("/domains/{name}", handleWithDatastore(db, c.DB.Driver, getDomainHandler)).Methods("GET")
-	r.HandleFunc("/domains/{name}/export", handleWithDatastore(db, c.DB.Driver, exportDomainHandler)).Methods("POST")
-	r.HandleFunc("/languages", handleWithDatastore(db, c.DB.Driver, getLanguagesHandler)).Methods("GET")
-	r.HandleFunc("/languages/{lang}", handleWithDatastore(db, c.DB.Driver, createLanguageHandler)).Methods("POST")
-	r.HandleFunc("/domains/{domain}/strings/{string}", handleWithDatastore(db, c.DB.Driver, deleteStringHandler)).Methods("DELETE")
-	r.HandleFunc("/domains/{domain}/strings/{string}/translations/{lang}", handleWithDatastore(db, c.DB.Driver, deleteTranslationHandler)).Methods("DELETE")
-	r.HandleFunc("/domains/{domain}/strings/{string}/translations/{lang}", handleWithDatastore(db, c.DB.Driver, createOrUpdateTranslationHandler)).Methods("POST", "PUT")
-	r.HandleFunc("/export", handleWithDatastore(db, c.DB.Driver, exportAllDomainsHandler)).Methods("POST")
-	r.HandleFunc("/search", handleWithDatastore(db, c.DB.Driver, searchHandler)).Methods("GET")
+	r.HandleFunc("/domains", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, getDomainsHandler))).Methods("GET")
+	r.HandleFunc("/domains/{name}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, getDomainHandler))).Methods("GET")
+	r.HandleFunc("/domains/{name}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, createDomainHandler))).Methods("POST")
+	r.HandleFunc("/domains/{name}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, updateDomainHandler))).Methods("PATCH")
+	r.HandleFunc("/domains/{name}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, deleteDomainHandler))).Methods("DELETE")
+	r.HandleFunc("/domains/{name}/archived", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, getArchivedStringsHandler))).Methods("GET")
+	r.HandleFunc("/domains/{name}/missing", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, getMissingStringsHandler))).Methods("GET")
+	r.HandleFunc("/domains/{name}/export", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, exportDomainHandler))).Methods("POST")
+	r.HandleFunc("/languages", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, getLanguagesHandler))).Methods("GET")
+	r.HandleFunc("/languages/{lang}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, getLanguageHandler))).Methods("GET")
+	r.HandleFunc("/languages/{lang}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, createLanguageHandler))).Methods("POST")
+	r.HandleFunc("/languages/{lang}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, updateLanguageHandler))).Methods("PUT")
+	r.HandleFunc("/languages/{lang}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, deleteLanguageHandler))).Methods("DELETE")
+	r.HandleFunc("/domains/{domain}/strings/{string}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, deleteStringHandler))).Methods("DELETE")
+	r.HandleFunc("/domains/{domain}/strings/{string}/restore", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, restoreStringHandler))).Methods("POST")
+	r.HandleFunc("/domains/{domain}/strings/{string}/translations/{lang}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleTranslator, deleteTranslationHandler))).Methods("DELETE")
+	r.HandleFunc("/domains/{domain}/strings/{string}/translations/{lang}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleTranslator, createOrUpdateTranslationHandler))).Methods("POST", "PUT")
+	r.HandleFunc("/domains/{domain}/strings/{string}/translations/{lang}/history", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, getTranslationHistoryHandler))).Methods("GET")
+	r.HandleFunc("/domains/{domain}/strings/{string}/translations/{lang}/revert/{revision}", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleTranslator, revertTranslationHandler))).Methods("POST")
+	r.HandleFunc("/domains/{domain}/strings/{string}/translations/{lang}/status", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleTranslator, setTranslationStatusHandler))).Methods("PUT")
+	r.HandleFunc("/export", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleAdmin, exportAllDomainsHandler))).Methods("POST")
+	r.HandleFunc("/outdated", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, getOutdatedTranslationsHandler))).Methods("GET")
+	r.HandleFunc("/search", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, searchHandler))).Methods("GET")
+	r.HandleFunc("/stats", handleWithDatastore(db, c.DB.Driver, authorize(datastore.RoleViewer, getTranslationStatsHandler))).Methods("GET")
+	r.Use(authenticate(db, c.DB.Driver))
 
 	rWithMiddleWares := handlers.CombinedLoggingHandler(os.Stdout, setJsonHeaders(r))
 
@@ -1,12 +1,15 @@
 package server
 
 import (
+	"github.com/toolani/go-translation-api/datastore"
 	"github.com/toolani/go-translation-api/trans"
+	"time"
 )
 
 type Domain struct {
-	Name    string   `json:"name"`
-	Strings []String `json:"strings"`
+	Name           string   `json:"name"`
+	SourceLanguage string   `json:"source_language"`
+	Strings        []String `json:"strings"`
 }
 
 func NewDomain(dd trans.Domain) (d *Domain) {
@@ -16,7 +19,12 @@ func NewDomain(dd trans.Domain) (d *Domain) {
 	for i, s := range ds {
 		ns := String{Name: s.Name(), Translations: make(map[string]Translation)}
 		for l, t := range s.Translations() {
-			ns.Translations[l.Code] = Translation{Content: t.Content()}
+			nt := Translation{Content: t.Content()}
+			if dt, ok := t.(*datastore.Translation); ok {
+				nt.Status = dt.Status()
+				nt.Outdated = dt.Outdated()
+			}
+			ns.Translations[l.Code] = nt
 		}
 		d.Strings[i] = ns
 	}
@@ -24,6 +32,17 @@ func NewDomain(dd trans.Domain) (d *Domain) {
 	return d
 }
 
+// Removes the translations into the languages whose codes keep returns false for
+func (d *Domain) keepLanguages(keep func(code string) bool) {
+	for _, s := range d.Strings {
+		for code := range s.Translations {
+			if !keep(code) {
+				delete(s.Translations, code)
+			}
+		}
+	}
+}
+
 type String struct {
 	Name         string                 `json:"name"`
 	Translations map[string]Translation `json:"translations"`
@@ -31,4 +50,51 @@ type String struct {
 
 type Translation struct {
 	Content string `json:"content"`
+	// Workflow status, one of the datastore.Status* constants. Empty for archived translations.
+	Status string `json:"status,omitempty"`
+	// Whether the source text of the string has changed since the translation was last written
+	Outdated bool `json:"outdated,omitempty"`
+}
+
+// MissingStrings lists the strings of a domain that have no translation into a language.
+type MissingStrings struct {
+	Name     string   `json:"name"`
+	Language string   `json:"language"`
+	Strings  []string `json:"strings"`
+}
+
+type ArchivedDomain struct {
+	Name    string           `json:"name"`
+	Strings []ArchivedString `json:"strings"`
+}
+
+func NewArchivedDomain(name string, strs []datastore.ArchivedString) (d *ArchivedDomain) {
+	d = &ArchivedDomain{Name: name, Strings: make([]ArchivedString, len(strs))}
+
+	for i, s := range strs {
+		ns := ArchivedString{Name: s.Name, ArchivedAt: s.ArchivedAt, Translations: make(map[string]Translation)}
+		for code, content := range s.Translations {
+			ns.Translations[code] = Translation{Content: content}
+		}
+		d.Strings[i] = ns
+	}
+
+	return d
+}
+
+// Removes the translations into the languages whose codes keep returns false for
+func (d *ArchivedDomain) keepLanguages(keep func(code string) bool) {
+	for _, s := range d.Strings {
+		for code := range s.Translations {
+			if !keep(code) {
+				delete(s.Translations, code)
+			}
+		}
+	}
+}
+
+type ArchivedString struct {
+	Name         string                 `json:"name"`
+	ArchivedAt   time.Time              `json:"archived_at"`
+	Translations map[string]Translation `json:"translations"`
 }
@@ -0,0 +1,296 @@
+/*
+Package sheet implements reading and writing of translations as CSV and XLSX spreadsheets, for
+handing off to translators.
+
+A sheet has one row per string, with the columns 'domain', 'string' and then one column for each
+language, headed by the language's code. Strings from any number of domains can be held in the same
+sheet. The file's extension, either '.csv' or '.xlsx', determines how it is read or written.
+
+CSV files are written in UTF-8 with a byte order mark, so that spreadsheet applications detect their
+encoding. XLSX files hold the sheet in their first worksheet.
+*/
+package sheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DomainColumn = "domain"
+	StringColumn = "string"
+
+	// Name of the worksheet that XLSX files are written to
+	worksheetName = "Translations"
+
+	byteOrderMark = "\ufeff"
+)
+
+type Sheet struct {
+	// Codes of the languages that have a column, in column order
+	Languages []string
+	Rows      []*Row
+}
+
+type Row struct {
+	// Number of the row in the file that it was read from, counting from 1 for the header row
+	Number int
+	Domain string
+	String string
+	// Content of the row's cells, keyed by language code. Empty cells are not included when reading.
+	Contents map[string]string
+}
+
+// Creates a new, empty Sheet.
+func New() *Sheet {
+	return &Sheet{}
+}
+
+// Adds a language column, if the sheet does not already have one for the language
+func (s *Sheet) addLanguage(code string) {
+	for _, l := range s.Languages {
+		if l == code {
+			return
+		}
+	}
+
+	s.Languages = append(s.Languages, code)
+}
+
+// AddDomain adds a row to the sheet for each of the domain's strings. Columns are added for the
+// domain's source language, followed by any other languages that it has translations for.
+func (s *Sheet) AddDomain(d trans.Domain, sourceLang trans.Language) {
+	s.addLanguage(sourceLang.Code)
+
+	strs := append([]trans.String(nil), d.Strings()...)
+	sort.Slice(strs, func(i, j int) bool { return strs[i].Name() < strs[j].Name() })
+
+	var codes []string
+	seen := make(map[string]bool)
+	for _, str := range strs {
+		row := &Row{Domain: d.Name(), String: str.Name(), Contents: make(map[string]string)}
+		for l, t := range str.Translations() {
+			row.Contents[l.Code] = t.Content()
+			if !seen[l.Code] {
+				seen[l.Code] = true
+				codes = append(codes, l.Code)
+			}
+		}
+		s.Rows = append(s.Rows, row)
+	}
+
+	sort.Strings(codes)
+	for _, code := range codes {
+		s.addLanguage(code)
+	}
+}
+
+// Gets the sheet's header and rows as lists of cells
+func (s *Sheet) records() (records [][]string) {
+	records = append(records, append([]string{DomainColumn, StringColumn}, s.Languages...))
+	for _, row := range s.Rows {
+		record := []string{row.Domain, row.String}
+		for _, code := range s.Languages {
+			record = append(record, row.Contents[code])
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// WriteFile writes the sheet to the file at the given path, which must have either a '.csv' or
+// '.xlsx' extension. The file's directory is created if necessary.
+func (s *Sheet) WriteFile(file string) (err error) {
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext != ".csv" && ext != ".xlsx" {
+		return errors.New(fmt.Sprintf("Unrecognised spreadsheet file extension '%v', expected '.csv' or '.xlsx'", ext))
+	}
+
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+
+	if ext == ".xlsx" {
+		return s.writeXlsx(file)
+	}
+
+	return s.writeCsv(file)
+}
+
+func (s *Sheet) writeCsv(file string) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.WriteString(f, byteOrderMark)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	err = w.WriteAll(s.records())
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *Sheet) writeXlsx(file string) (err error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	err = f.SetSheetName(f.GetSheetName(0), worksheetName)
+	if err != nil {
+		return err
+	}
+
+	for i, record := range s.records() {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+
+		values := make([]interface{}, len(record))
+		for j, v := range record {
+			values[j] = v
+		}
+		err = f.SetSheetRow(worksheetName, cell, &values)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keep the header row in view while scrolling
+	err = f.SetPanes(worksheetName, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	if err != nil {
+		return err
+	}
+
+	return f.SaveAs(file)
+}
+
+// Creates a new Sheet from the file at the given path, which must have either a '.csv' or '.xlsx'
+// extension. The file's first row must hold the column headers.
+func NewFromFile(file string) (s *Sheet, err error) {
+	var records [][]string
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		records, err = readCsv(file)
+	case ".xlsx":
+		records, err = readXlsx(file)
+	default:
+		return nil, errors.New(fmt.Sprintf("Unrecognised spreadsheet file extension in file '%v', expected '.csv' or '.xlsx'", file))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s, err = fromRecords(records)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v in file '%v'", err, file))
+	}
+
+	return s, nil
+}
+
+func readCsv(file string) (records [][]string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err = r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) > 0 && len(records[0]) > 0 {
+		records[0][0] = strings.TrimPrefix(records[0][0], byteOrderMark)
+	}
+
+	return records, nil
+}
+
+func readXlsx(file string) (records [][]string, err error) {
+	f, err := excelize.OpenFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.GetRows(f.GetSheetName(0))
+}
+
+// Creates a Sheet from a header and rows of cells. Rows whose cells are all empty are skipped.
+func fromRecords(records [][]string) (s *Sheet, err error) {
+	if len(records) == 0 {
+		return nil, errors.New("Missing header row")
+	}
+
+	header := records[0]
+	if len(header) < 2 ||
+		!strings.EqualFold(strings.TrimSpace(header[0]), DomainColumn) ||
+		!strings.EqualFold(strings.TrimSpace(header[1]), StringColumn) {
+		return nil, errors.New(fmt.Sprintf("Header row must start with '%v' and '%v' columns", DomainColumn, StringColumn))
+	}
+
+	s = New()
+	// Language code of each column, or "" for columns that are ignored
+	columns := make([]string, len(header))
+	for i, h := range header[2:] {
+		code := strings.ToLower(strings.Replace(strings.TrimSpace(h), "_", "-", -1))
+		if code == "" {
+			continue
+		}
+		for _, l := range s.Languages {
+			if l == code {
+				return nil, errors.New(fmt.Sprintf("Duplicate column for language '%v'", code))
+			}
+		}
+		s.Languages = append(s.Languages, code)
+		columns[i+2] = code
+	}
+
+	for i, record := range records[1:] {
+		row := &Row{Number: i + 2, Contents: make(map[string]string)}
+		empty := true
+		for j, cell := range record {
+			if cell == "" {
+				continue
+			}
+			empty = false
+
+			switch {
+			case j == 0:
+				row.Domain = strings.TrimSpace(cell)
+			case j == 1:
+				row.String = cell
+			case j < len(columns) && columns[j] != "":
+				row.Contents[columns[j]] = cell
+			}
+		}
+
+		if !empty {
+			s.Rows = append(s.Rows, row)
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,289 @@
+/*
+Package tmx implements reading and writing of TMX 1.4b translation memory documents.
+
+Each string is written as a <tu> whose 'tuid' is the string's name, with a <tuv> holding its
+translation into each language. Strings from several domains can be written to the same document,
+in which case each <tu> records the name of its domain in an 'x-domain' <prop>.
+
+When reading, each <tu> becomes a string of a single domain chosen by the caller. Units without a
+'tuid' are named after their source language text instead. Inline markup within segments, such as
+<ph> or <bpt> elements, is not supported and only the text of each segment is read.
+*/
+package tmx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	Version = "1.4"
+
+	// Source language of documents whose units do not all share the same source language
+	AllLanguages = "*all*"
+
+	// Type of the <prop> holding the name of a unit's domain
+	DomainProp = "x-domain"
+)
+
+type Tmx struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  TmxHeader `xml:"header"`
+	Units   []*TmxTu  `xml:"body>tu"`
+}
+
+type TmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTmf                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+	CreationDate        string `xml:"creationdate,attr,omitempty"`
+}
+
+// TmxTu is a translation unit, holding the translations of a single string
+type TmxTu struct {
+	TuId     string     `xml:"tuid,attr,omitempty"`
+	SrcLang  string     `xml:"srclang,attr,omitempty"`
+	Props    []*TmxProp `xml:"prop"`
+	Notes    []string   `xml:"note"`
+	Variants []*TmxTuv  `xml:"tuv"`
+}
+
+type TmxProp struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// TmxTuv is a translation unit variant, holding the translation of a string into one language
+type TmxTuv struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	// The 'lang' attribute used by TMX 1.1 and earlier, only used when reading
+	OldLang string `xml:"lang,attr,omitempty"`
+	Seg     string `xml:"seg"`
+}
+
+// Gets the language code of the variant, as used in the database
+func (tuv TmxTuv) language() string {
+	lang := tuv.Lang
+	if lang == "" {
+		lang = tuv.OldLang
+	}
+
+	return strings.ToLower(strings.Replace(lang, "_", "-", -1))
+}
+
+type TmxDomain struct {
+	name  string
+	Units []*TmxString
+}
+
+func (td TmxDomain) Name() string {
+	return td.name
+}
+func (td *TmxDomain) SetName(name string) {
+	td.name = name
+}
+func (td TmxDomain) Strings() []trans.String {
+	ss := make([]trans.String, len(td.Units))
+	for i, s := range td.Units {
+		ss[i] = s
+	}
+
+	return ss
+}
+
+type TmxString struct {
+	name         string
+	translations map[trans.Language]trans.Translation
+}
+
+func (ts TmxString) Name() string {
+	return ts.name
+}
+func (ts TmxString) Translations() map[trans.Language]trans.Translation {
+	return ts.translations
+}
+
+type TmxTranslation struct {
+	content string
+}
+
+func (tt TmxTranslation) Content() string {
+	return tt.content
+}
+
+// Creates a new, empty TMX document whose units are mostly in the given source language.
+func New(sourceLang string) (tmx *Tmx) {
+	tmx = &Tmx{Version: Version}
+
+	tmx.Header.CreationTool = "go-translation-api"
+	tmx.Header.CreationToolVersion = "1.0.0-alpha"
+	tmx.Header.SegType = "block"
+	tmx.Header.OTmf = "go-translation-api"
+	tmx.Header.AdminLang = "en"
+	tmx.Header.SrcLang = sourceLang
+	tmx.Header.DataType = "plaintext"
+	tmx.Header.CreationDate = time.Now().UTC().Format("20060102T150405Z")
+
+	return tmx
+}
+
+// Creates a new Tmx from the file at the given path. UTF-16 encoded files, which must start with a
+// byte order mark, are supported as well as UTF-8 ones.
+func NewFromFile(file string) (tmx *Tmx, err error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(decodeUtf16(data)))
+	// The content has already been converted to UTF-8, whatever the XML declaration says
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	tmx = &Tmx{}
+	err = dec.Decode(tmx)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v in file '%v'", err, file))
+	}
+
+	return tmx, nil
+}
+
+// Converts UTF-16 data with a byte order mark to UTF-8. Other data is returned unchanged.
+func decodeUtf16(data []byte) []byte {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		order = binary.BigEndian
+	default:
+		return data
+	}
+
+	units := make([]uint16, (len(data)-2)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2+i*2:])
+	}
+
+	return []byte(string(utf16.Decode(units)))
+}
+
+// Domain gets the document's units as the strings of a domain with the given name. Each unit's
+// 'tuid' is used as its string's name, falling back to the text of its source language variant.
+// Units with neither are skipped.
+func (tmx *Tmx) Domain(name string) *TmxDomain {
+	td := &TmxDomain{name: name}
+
+	for _, tu := range tmx.Units {
+		srcLang := tu.SrcLang
+		if srcLang == "" {
+			srcLang = tmx.Header.SrcLang
+		}
+		srcLang = TmxTuv{Lang: srcLang}.language()
+
+		ts := &TmxString{name: tu.TuId, translations: make(map[trans.Language]trans.Translation)}
+		for i, tuv := range tu.Variants {
+			code := tuv.language()
+			if code == "" {
+				continue
+			}
+			if ts.name == "" && (code == srcLang || (srcLang == AllLanguages && i == 0)) {
+				ts.name = tuv.Seg
+			}
+			ts.translations[trans.Language{Code: code}] = TmxTranslation{content: tuv.Seg}
+		}
+
+		if ts.name != "" {
+			td.Units = append(td.Units, ts)
+		}
+	}
+
+	return td
+}
+
+// AddDomain adds a unit to the document for each of the domain's strings, with a variant for each
+// of the string's translations. sourceLang is the language that the domain is translated from.
+func (tmx *Tmx) AddDomain(d trans.Domain, sourceLang trans.Language) {
+	if tmx.Header.SrcLang == "" {
+		tmx.Header.SrcLang = sourceLang.Code
+	}
+
+	srcLang := ""
+	if sourceLang.Code != tmx.Header.SrcLang {
+		srcLang = sourceLang.Code
+	}
+
+	strs := append([]trans.String(nil), d.Strings()...)
+	sort.Slice(strs, func(i, j int) bool { return strs[i].Name() < strs[j].Name() })
+
+	for _, s := range strs {
+		ts := s.Translations()
+		if len(ts) == 0 {
+			continue
+		}
+
+		tu := &TmxTu{
+			TuId:    s.Name(),
+			SrcLang: srcLang,
+			Props:   []*TmxProp{{Type: DomainProp, Value: d.Name()}},
+			Notes:   trans.InfoOf(s).Notes,
+		}
+
+		// Variants are written in language order, with the source language first
+		codes := make([]string, 0, len(ts))
+		contents := make(map[string]string)
+		for l, t := range ts {
+			codes = append(codes, l.Code)
+			contents[l.Code] = t.Content()
+		}
+		sort.Slice(codes, func(i, j int) bool {
+			if codes[i] == sourceLang.Code || codes[j] == sourceLang.Code {
+				return codes[i] == sourceLang.Code
+			}
+			return codes[i] < codes[j]
+		})
+
+		for _, code := range codes {
+			tu.Variants = append(tu.Variants, &TmxTuv{Lang: code, Seg: contents[code]})
+		}
+
+		tmx.Units = append(tmx.Units, tu)
+	}
+}
+
+// WriteFile writes the document as UTF-8 to the file at the given path, creating the file's
+// directory if necessary.
+func (tmx *Tmx) WriteFile(file string) (err error) {
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+
+	output, err := xml.MarshalIndent(tmx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	content := []byte(xml.Header + string(output) + "\n")
+
+	return ioutil.WriteFile(file, content, 0644)
+}
@@ -0,0 +1,214 @@
+package trans
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Naming convention of translation files, used unless another pattern is set
+const DefaultFilenamePattern = "{domain}.{lang}.{ext}"
+
+// Suffix that Symfony adds to the domain name of files holding ICU MessageFormat translations, e.g.
+// 'messages+intl-icu.fr.xlf'
+const icuDomainSuffix = "+intl-icu"
+
+// Regular expressions matched by each of the placeholders of a filename pattern. Domain names may
+// contain any characters, including '.', while languages must look like a language code.
+var patternPlaceholders = map[string]string{
+	"{domain}": `(?P<domain>.+)`,
+	"{lang}":   `(?P<lang>[A-Za-z]{2,3}(?:[-_][A-Za-z0-9]{2,8})*)`,
+	"{ext}":    `[A-Za-z0-9]+`,
+}
+
+// FilenamePattern describes how the domain name and language code are given by the names of
+// translation files, e.g. '{domain}.{lang}.{ext}'.
+type FilenamePattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// ParseFilenamePattern parses a filename pattern. Patterns must contain the '{domain}' and '{lang}'
+// placeholders once each, and may contain '{ext}' for the file's extension. Any other text must
+// match the file's name exactly.
+func ParseFilenamePattern(pattern string) (p *FilenamePattern, err error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+
+	rest := pattern
+	for rest != "" {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			expr.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return nil, errors.New(fmt.Sprintf("Unclosed placeholder in filename pattern '%v'", pattern))
+		}
+		end += start + 1
+
+		placeholder := rest[start:end]
+		re, ok := patternPlaceholders[placeholder]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unknown placeholder '%v' in filename pattern '%v'", placeholder, pattern))
+		}
+		expr.WriteString(regexp.QuoteMeta(rest[:start]))
+		expr.WriteString(re)
+		rest = rest[end:]
+	}
+	expr.WriteString("$")
+
+	for _, placeholder := range []string{"{domain}", "{lang}"} {
+		if strings.Count(pattern, placeholder) != 1 {
+			return nil, errors.New(fmt.Sprintf("Filename pattern '%v' must contain '%v' exactly once", pattern, placeholder))
+		}
+	}
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &FilenamePattern{pattern: pattern, re: re}, nil
+}
+
+func (p *FilenamePattern) String() string {
+	return p.pattern
+}
+
+// Match gets the domain name and language code from the name of a file following the pattern. Any
+// '+intl-icu' suffix is removed from the domain name.
+func (p *FilenamePattern) Match(filename string) (name string, lang string, err error) {
+	m := p.re.FindStringSubmatch(filename)
+	if m == nil {
+		return "", "", errors.New(fmt.Sprintf("Domain name or language missing from filename '%v', expected a name like '%v'", filename, p.pattern))
+	}
+
+	name = strings.TrimSuffix(m[p.re.SubexpIndex("domain")], icuDomainSuffix)
+	lang = m[p.re.SubexpIndex("lang")]
+	if name == "" {
+		return "", "", errors.New(fmt.Sprintf("Domain name missing from filename '%v'", filename))
+	}
+
+	return name, lang, nil
+}
+
+var (
+	defaultFilenamePattern, _ = ParseFilenamePattern(DefaultFilenamePattern)
+	filenamePattern           = defaultFilenamePattern
+)
+
+// SetFilenamePattern sets the pattern used by InfoFromFilename, e.g. '{domain}-{lang}.{ext}'. See
+// ParseFilenamePattern for the pattern's syntax.
+func SetFilenamePattern(pattern string) error {
+	p, err := ParseFilenamePattern(pattern)
+	if err != nil {
+		return err
+	}
+	filenamePattern = p
+
+	return nil
+}
+
+// Gets the domain name and language code from the name of a file being imported. Names follow the
+// '[domain].[language].[extension]' naming convention, unless another pattern has been set using
+// SetFilenamePattern. Domain names may contain '.' characters, e.g. 'admin.menu.de.xlf'.
+func InfoFromFilename(filename string) (name string, lang string, err error) {
+	return filenamePattern.Match(filename)
+}
+
+// Removes files with the given extension that follow the '[domain].[language].[extension]' naming
+// convention from dir. Only files belonging to the given domain and language are removed, where an
+// empty domain or language matches any.
+func RemoveFiles(dir, ext, domain, lang string) (err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*."+ext))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		// Exported files always use the default naming convention
+		fileDomain, fileLang, err := defaultFilenamePattern.Match(filepath.Base(file))
+		if err != nil || (domain != "" && fileDomain != domain) || (lang != "" && fileLang != lang) {
+			continue
+		}
+
+		if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Converts a language code to the locale form used in Java and Flutter style file names, e.g.
+// 'de-ch' to 'de_CH'.
+func UnderscoreLocale(code string) string {
+	parts := strings.SplitN(code, "-", 2)
+	if len(parts) == 1 {
+		return strings.ToLower(parts[0])
+	}
+
+	return strings.ToLower(parts[0]) + "_" + strings.ToUpper(parts[1])
+}
+
+// Gets the domain name and language code from the name of a file following the Java and Flutter
+// style '[domain]_[locale].[extension]' naming convention, e.g. 'messages_de_CH.properties'. The
+// language is empty for files without a locale, e.g. 'messages.properties'.
+func InfoFromLocaleFilename(filename string) (name string, lang string, err error) {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	parts := strings.Split(base, "_")
+	n := len(parts)
+
+	// Only two letter languages and two letter or three digit regions are recognised, so that
+	// domains containing underscores, e.g. 'my_app.properties', are not mistaken for a locale
+	isLang := func(s string) bool { return len(s) == 2 && strings.ToLower(s) == s && strings.ToUpper(s) != s }
+	isRegion := func(s string) bool {
+		if len(s) == 3 {
+			return strings.Trim(s, "0123456789") == ""
+		}
+		return len(s) == 2 && strings.ToUpper(s) == s && strings.ToLower(s) != s
+	}
+
+	switch {
+	case n >= 3 && isLang(parts[n-2]) && isRegion(parts[n-1]):
+		name, lang = strings.Join(parts[:n-2], "_"), parts[n-2]+"-"+strings.ToLower(parts[n-1])
+	case n >= 2 && isLang(parts[n-1]):
+		name, lang = strings.Join(parts[:n-1], "_"), parts[n-1]
+	default:
+		name = base
+	}
+
+	if name == "" {
+		return "", "", errors.New(fmt.Sprintf("Domain name missing from filename '%v'", filename))
+	}
+
+	return name, lang, nil
+}
+
+// Removes files with the given extension that follow the '[domain]_[locale].[extension]' naming
+// convention from dir. Only files belonging to the given domain and language are removed, where an
+// empty domain or language matches any. Files without a locale are only removed when lang is empty.
+func RemoveLocaleFiles(dir, ext, domain, lang string) (err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*."+ext))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		fileDomain, fileLang, err := InfoFromLocaleFilename(filepath.Base(file))
+		if err != nil || (domain != "" && fileDomain != domain) || (lang != "" && fileLang != lang) {
+			continue
+		}
+
+		if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package trans
+
+import (
+	"strings"
+)
+
+// Separates the plural forms of a translation's content, as used by Symfony's translator. For
+// example: 'apple|apples'.
+const PluralSeparator = "|"
+
+// CLDR plural categories used for each number of plural forms
+var pluralCategories = map[int][]string{
+	2: {"one", "other"},
+	3: {"one", "few", "other"},
+	4: {"one", "few", "many", "other"},
+	6: {"zero", "one", "two", "few", "many", "other"},
+}
+
+// All CLDR plural categories, in the order that their forms appear in a translation's content
+var AllPluralCategories = []string{"zero", "one", "two", "few", "many", "other"}
+
+// Splits content into its plural forms. Content without plural forms gives a single form.
+func PluralForms(content string) []string {
+	return strings.Split(content, PluralSeparator)
+}
+
+// Gets the CLDR plural categories (e.g. 'one', 'other') of each of the given number of plural
+// forms. Returns nil if there is no known mapping for that number of forms.
+func PluralCategories(forms int) []string {
+	return pluralCategories[forms]
+}
@@ -23,3 +23,79 @@ type Language struct {
 	Code string `json:"code"` // language / locale code
 	Name string `json:"name"`
 }
+
+// Extra information about a string, which is kept alongside it by formats that support it
+type StringInfo struct {
+	// Notes for translators, e.g. a description of where the string is used
+	Notes []string
+	// Places in source code where the string is used
+	References []Reference
+	// Data that is not used, but should be preserved, such as an XLIFF 'extradata' attribute
+	ExtraData string
+}
+
+// A place in a source code file
+type Reference struct {
+	File string
+	// Line number, or 0 if not known
+	Line int
+}
+
+// A string that has extra information
+type InfoString interface {
+	String
+	Info() StringInfo
+}
+
+// Gets the extra information of s, which is empty if s does not provide any.
+func InfoOf(s String) StringInfo {
+	if is, ok := s.(InfoString); ok {
+		return is.Info()
+	}
+
+	return StringInfo{}
+}
+
+// Empty checks whether the StringInfo has no information.
+func (si StringInfo) Empty() bool {
+	return len(si.Notes) == 0 && len(si.References) == 0 && si.ExtraData == ""
+}
+
+// The progress of a translation, as tracked by XLIFF files
+type TranslationState struct {
+	// An XLIFF 1.2 target state, e.g. 'needs-translation' or 'translated'. May be empty.
+	State string
+	// Whether the translation has been approved
+	Approved bool
+}
+
+// A translation that has a state
+type StatefulTranslation interface {
+	Translation
+	State() TranslationState
+}
+
+// Gets the state of t, which is empty if t does not provide one.
+func StateOf(t Translation) TranslationState {
+	if st, ok := t.(StatefulTranslation); ok {
+		return st.State()
+	}
+
+	return TranslationState{}
+}
+
+// A translation that may need to be looked at again by a translator
+type ReviewableTranslation interface {
+	Translation
+	NeedsReview() bool
+}
+
+// NeedsReview checks whether t needs to be looked at again by a translator, which is false if t does
+// not say.
+func NeedsReview(t Translation) bool {
+	if rt, ok := t.(ReviewableTranslation); ok {
+		return rt.NeedsReview()
+	}
+
+	return false
+}
@@ -9,15 +9,16 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
 type Xliff struct {
-	XMLName   xml.Name  `xml:"xliff"`
-	Namespace string    `xml:"xmlns,attr"`
-	Version   string    `xml:"version,attr"`
-	File      XliffFile `xml:"file"`
+	XMLName   xml.Name `xml:"xliff"`
+	Namespace string   `xml:"xmlns,attr"`
+	// Declares the 'jms' prefix used for reference files. Only used when writing.
+	JmsNamespace string    `xml:"xmlns:jms,attr,omitempty"`
+	Version      string    `xml:"version,attr"`
+	File         XliffFile `xml:"file"`
 }
 
 type XliffFile struct {
@@ -62,11 +63,35 @@ func (xd XliffDomain) Strings() []trans.String {
 }
 
 type XliffString struct {
-	language         *trans.Language
-	Hash             string `xml:"id,attr"`
-	TransUnitName    string `xml:"resname,attr"`
-	Source           string `xml:"source"`
-	TransUnitContent string `xml:"target"`
+	language      *trans.Language
+	Hash          string           `xml:"id,attr"`
+	TransUnitName string           `xml:"resname,attr"`
+	Approved      string           `xml:"approved,attr,omitempty"`
+	ExtraData     string           `xml:"extradata,attr,omitempty"`
+	Source        string           `xml:"source"`
+	Target        XliffTarget      `xml:"target"`
+	Notes         []string         `xml:"note"`
+	References    []XliffReference `xml:"urn:jms:translation reference-file"`
+}
+
+type XliffTarget struct {
+	Content string `xml:",chardata"`
+	State   string `xml:"state,attr,omitempty"`
+}
+
+// XliffReference is a source code reference, as written by JMSTranslationBundle.
+type XliffReference struct {
+	File string `xml:",chardata"`
+	Line int    `xml:"line,attr,omitempty"`
+}
+
+// Writes the reference as a <jms:reference-file> element, so that it matches the files written by
+// JMSTranslationBundle. The 'jms' prefix is declared by the document's JmsNamespace.
+func (xr XliffReference) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type reference XliffReference
+	start.Name = xml.Name{Local: "jms:reference-file"}
+
+	return e.EncodeElement(reference(xr), start)
 }
 
 func (xs XliffString) Name() string {
@@ -79,16 +104,18 @@ func (xs XliffString) Translations() map[trans.Language]trans.Translation {
 	return ts
 }
 func (xs XliffString) Content() string {
-	return xs.TransUnitContent
+	return xs.Target.Content
 }
-
-func infoFromFilename(filename string) (name string, expectLang string, err error) {
-	parts := strings.Split(filename, ".")
-	if len(parts) != 3 {
-		return "", "", errors.New(fmt.Sprintf("Domain name or language missing from filename '%v'", filename))
+func (xs XliffString) Info() trans.StringInfo {
+	info := trans.StringInfo{Notes: xs.Notes, ExtraData: xs.ExtraData}
+	for _, r := range xs.References {
+		info.References = append(info.References, trans.Reference{File: r.File, Line: r.Line})
 	}
 
-	return parts[0], parts[1], nil
+	return info
+}
+func (xs XliffString) State() trans.TranslationState {
+	return trans.TranslationState{State: xs.Target.State, Approved: xs.Approved == "yes"}
 }
 
 func hash(input string) (hash string) {
@@ -100,7 +127,7 @@ func hash(input string) (hash string) {
 }
 
 func New(name, sourceLang, targetLang string) (xliff *Xliff) {
-	xliff = &Xliff{Namespace: "urn:oasis:names:tc:xliff:document:1.2", Version: "1.2"}
+	xliff = &Xliff{Namespace: Namespace12, Version: Version12}
 
 	xliff.File.Date = "2014-10-15T16:00:00Z"
 	xliff.File.Date = time.Now().Format(time.RFC3339)
@@ -118,20 +145,35 @@ func New(name, sourceLang, targetLang string) (xliff *Xliff) {
 	return xliff
 }
 
-// Creates a new Xliff from the file at the given path
+// Creates a new Xliff from the file at the given path. The file may be either an XLIFF 1.2 or 2.0
+// document, as determined by the namespace of its root element.
 func NewFromFile(file string) (xliff *Xliff, err error) {
 	xliffData, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
 
-	xliff = &Xliff{}
-	err = xml.Unmarshal(xliffData, xliff)
+	ns, err := rootNamespace(xliffData)
 	if err != nil {
 		return nil, err
 	}
 
-	if name, expectLang, err := infoFromFilename(filepath.Base(file)); err != nil {
+	if ns == Namespace20 {
+		x2 := &Xliff2{}
+		err = xml.Unmarshal(xliffData, x2)
+		if err != nil {
+			return nil, err
+		}
+		xliff = x2.toXliff()
+	} else {
+		xliff = &Xliff{}
+		err = xml.Unmarshal(xliffData, xliff)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if name, expectLang, err := trans.InfoFromFilename(filepath.Base(file)); err != nil {
 		return nil, err
 	} else {
 		if xliff.File.XliffDomain.TargetLang != expectLang {
@@ -161,7 +203,13 @@ func getTranslation(s trans.String, l trans.Language) (t trans.Translation) {
 	return nil
 }
 
-func Export(source trans.Domain, sourceLang trans.Language, dir string) (err error) {
+// Exports the domain to a '[domain].[language].xliff' file for each language that it has
+// translations for. version is the XLIFF version to write, one of Version12 or Version20. When
+// skipSource is true, no file is written for the source language.
+func Export(source trans.Domain, sourceLang trans.Language, dir, version string, skipSource bool) (err error) {
+	if version != Version12 && version != Version20 {
+		return errors.New(fmt.Sprintf("Unsupported XLIFF version '%v'", version))
+	}
 
 	// Create output directory
 	err = os.MkdirAll(dir, 0755)
@@ -181,18 +229,30 @@ func Export(source trans.Domain, sourceLang trans.Language, dir string) (err err
 			sourceText = sourceTrans.Content()
 		}
 
+		info := trans.InfoOf(s)
+
 		for l, t := range s.Translations() {
+			state := trans.StateOf(t)
 			if _, ok := xliffs[l]; !ok {
 				xliffs[l] = New(source.Name(), sourceLang.Code, l.Code)
 			}
 			xliff := xliffs[l]
 
 			xs := &XliffString{
-				language:         &trans.Language{Id: l.Id, Code: l.Code, Name: l.Name},
-				Hash:             hash(s.Name()),
-				TransUnitName:    s.Name(),
-				TransUnitContent: t.Content(),
-				Source:           sourceText,
+				language:      &trans.Language{Id: l.Id, Code: l.Code, Name: l.Name},
+				Hash:          hash(s.Name()),
+				TransUnitName: s.Name(),
+				Source:        sourceText,
+				Target:        XliffTarget{Content: t.Content(), State: state.State},
+				Notes:         info.Notes,
+				ExtraData:     info.ExtraData,
+			}
+			if state.Approved {
+				xs.Approved = "yes"
+			}
+			for _, r := range info.References {
+				xs.References = append(xs.References, XliffReference{File: r.File, Line: r.Line})
+				xliff.JmsNamespace = NamespaceJms
 			}
 			xliff.File.XliffDomain.TransUnits = append(xliff.File.XliffDomain.TransUnits, xs)
 			xliffs[l] = xliff
@@ -200,7 +260,11 @@ func Export(source trans.Domain, sourceLang trans.Language, dir string) (err err
 	}
 
 	// Export each xliff to file
-	for _, xliff := range xliffs {
+	for l, xliff := range xliffs {
+		if skipSource && l.Code == sourceLang.Code {
+			continue
+		}
+
 		fileName := fmt.Sprintf("%v.%v.xliff", xliff.File.XliffDomain.name, xliff.File.XliffDomain.TargetLang)
 		f, err := os.Create(filepath.Join(dir, fileName))
 		if err != nil {
@@ -213,7 +277,12 @@ func Export(source trans.Domain, sourceLang trans.Language, dir string) (err err
 		}
 		enc := xml.NewEncoder(f)
 		enc.Indent("", "  ")
-		if err = enc.Encode(xliff); err != nil {
+		if version == Version20 {
+			err = enc.Encode(xliff.toXliff2())
+		} else {
+			err = enc.Encode(xliff)
+		}
+		if err != nil {
 			return err
 		}
 		f.Close()
@@ -221,3 +290,9 @@ func Export(source trans.Domain, sourceLang trans.Language, dir string) (err err
 
 	return nil
 }
+
+// Removes previously exported XLIFF files from the given directory. Only files belonging to the
+// given domain and language are removed, where an empty domain or language matches any.
+func Remove(dir, domain, lang string) (err error) {
+	return trans.RemoveFiles(dir, "xliff", domain, lang)
+}
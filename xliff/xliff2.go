@@ -0,0 +1,203 @@
+package xliff
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+const (
+	Version12 = "1.2"
+	Version20 = "2.0"
+
+	Namespace12 = "urn:oasis:names:tc:xliff:document:1.2"
+	Namespace20 = "urn:oasis:names:tc:xliff:document:2.0"
+
+	// Namespace of the elements that JMSTranslationBundle adds to XLIFF 1.2 files
+	NamespaceJms = "urn:jms:translation"
+)
+
+// Versions lists the XLIFF versions that can be read and written
+var Versions = []string{Version12, Version20}
+
+// ValidVersion checks whether version is one of the XLIFF versions that can be written.
+func ValidVersion(version string) bool {
+	for _, v := range Versions {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Xliff2 is an XLIFF 2.0 document. It is only used while reading and writing files, with its
+// content being converted to or from an Xliff.
+type Xliff2 struct {
+	XMLName   xml.Name   `xml:"xliff"`
+	Namespace string     `xml:"xmlns,attr"`
+	Version   string     `xml:"version,attr"`
+	SrcLang   string     `xml:"srcLang,attr"`
+	TrgLang   string     `xml:"trgLang,attr,omitempty"`
+	File      Xliff2File `xml:"file"`
+}
+
+type Xliff2File struct {
+	Id     string         `xml:"id,attr"`
+	Groups []*Xliff2Group `xml:"group"`
+	Units  []*Xliff2Unit  `xml:"unit"`
+}
+
+// Xliff2Group is a group of units, which may contain further groups. Groups are only read, their
+// units being treated as if they belonged directly to the file.
+type Xliff2Group struct {
+	Groups []*Xliff2Group `xml:"group"`
+	Units  []*Xliff2Unit  `xml:"unit"`
+}
+
+type Xliff2Unit struct {
+	Id       string           `xml:"id,attr"`
+	Name     string           `xml:"name,attr,omitempty"`
+	Notes    *Xliff2Notes     `xml:"notes,omitempty"`
+	Segments []*Xliff2Segment `xml:",any"`
+}
+
+type Xliff2Notes struct {
+	Notes []string `xml:"note"`
+}
+
+// Xliff2Segment is either a <segment> or an <ignorable> element of a unit. Both are read, in
+// order, as their content is joined to give the unit's content.
+type Xliff2Segment struct {
+	XMLName xml.Name
+	State   string `xml:"state,attr,omitempty"`
+	Source  string `xml:"source"`
+	Target  string `xml:"target"`
+}
+
+// XLIFF 2.0 segment states for each XLIFF 1.2 target state. Translations are stored with XLIFF 1.2
+// states, as these are more detailed.
+var states20 = map[string]string{
+	"new":                      "initial",
+	"needs-translation":        "initial",
+	"needs-adaptation":         "initial",
+	"needs-l10n":               "initial",
+	"needs-review-translation": "translated",
+	"needs-review-adaptation":  "translated",
+	"needs-review-l10n":        "translated",
+	"translated":               "translated",
+	"signed-off":               "reviewed",
+	"final":                    "final",
+}
+
+// XLIFF 1.2 target states for each XLIFF 2.0 segment state
+var states12 = map[string]string{
+	"initial":    "needs-translation",
+	"translated": "translated",
+	"reviewed":   "signed-off",
+	"final":      "final",
+}
+
+// Gets the namespace of the root element of an XML document
+func rootNamespace(data []byte) (ns string, err error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Space, nil
+		}
+	}
+}
+
+// Gets all of the group's units, including those of nested groups
+func (g *Xliff2Group) allUnits() []*Xliff2Unit {
+	units := g.Units
+	for _, child := range g.Groups {
+		units = append(units, child.allUnits()...)
+	}
+
+	return units
+}
+
+// Converts an XLIFF 2.0 document to an Xliff. Each unit's name is used as its string name, falling
+// back to its id if it has none.
+func (x2 *Xliff2) toXliff() *Xliff {
+	xliff := New("", x2.SrcLang, x2.TrgLang)
+	xliff.Namespace = x2.Namespace
+	xliff.Version = x2.Version
+	xliff.File.Original = x2.File.Id
+
+	root := Xliff2Group{Groups: x2.File.Groups, Units: x2.File.Units}
+	for _, u := range root.allUnits() {
+		name := u.Name
+		if name == "" {
+			name = u.Id
+		}
+
+		// The state of the unit is taken from its first segment
+		var source, target strings.Builder
+		state := ""
+		for _, seg := range u.Segments {
+			switch seg.XMLName.Local {
+			case "segment":
+				if state == "" {
+					state = states12[seg.State]
+				}
+			case "ignorable":
+			default:
+				continue
+			}
+			source.WriteString(seg.Source)
+			target.WriteString(seg.Target)
+		}
+
+		xs := &XliffString{
+			Hash:          u.Id,
+			TransUnitName: name,
+			Source:        source.String(),
+			Target:        XliffTarget{Content: target.String(), State: state},
+		}
+		if u.Notes != nil {
+			xs.Notes = u.Notes.Notes
+		}
+		xliff.File.XliffDomain.TransUnits = append(xliff.File.XliffDomain.TransUnits, xs)
+	}
+
+	return xliff
+}
+
+// Converts an Xliff to an XLIFF 2.0 document, with a single segment per unit. XLIFF 2.0 has no
+// equivalent of the approved and extradata attributes or of JMSTranslationBundle's references, so
+// these are not written.
+func (xliff *Xliff) toXliff2() *Xliff2 {
+	d := xliff.File.XliffDomain
+	x2 := &Xliff2{
+		Namespace: Namespace20,
+		Version:   Version20,
+		SrcLang:   d.SourceLang,
+		TrgLang:   d.TargetLang,
+		File:      Xliff2File{Id: d.name},
+	}
+
+	for _, xs := range d.TransUnits {
+		u := &Xliff2Unit{
+			Id:   xs.Hash,
+			Name: xs.TransUnitName,
+			Segments: []*Xliff2Segment{{
+				XMLName: xml.Name{Local: "segment"},
+				State:   states20[xs.Target.State],
+				Source:  xs.Source,
+				Target:  xs.Target.Content,
+			}},
+		}
+		if len(xs.Notes) > 0 {
+			u.Notes = &Xliff2Notes{Notes: xs.Notes}
+		}
+		x2.File.Units = append(x2.File.Units, u)
+	}
+
+	return x2
+}
@@ -0,0 +1,184 @@
+/*
+Package yml implements reading and writing of Symfony YAML translation files.
+
+Nested YAML keys are joined with '.' characters to give string names, in the same way that
+Symfony's translator flattens them. For example, this file contains a string named
+'homepage.title':
+
+	homepage:
+	  title: Welcome!
+
+Exported files always use flat, dot-joined keys, which Symfony reads identically.
+*/
+package yml
+
+import (
+	"errors"
+	"fmt"
+	"github.com/toolani/go-translation-api/trans"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Separates the parts of a nested key
+const keySeparator = "."
+
+type YamlDomain struct {
+	name     string
+	Language string
+	Messages []*YamlString
+}
+
+func (yd YamlDomain) Name() string {
+	return yd.name
+}
+func (yd *YamlDomain) SetName(name string) {
+	yd.name = name
+}
+func (yd YamlDomain) Strings() []trans.String {
+	ss := make([]trans.String, len(yd.Messages))
+	for i, m := range yd.Messages {
+		ss[i] = m
+	}
+
+	return ss
+}
+
+type YamlString struct {
+	language *trans.Language
+	Key      string
+	Value    string
+}
+
+func (ys YamlString) Name() string {
+	return ys.Key
+}
+func (ys YamlString) Translations() map[trans.Language]trans.Translation {
+	ts := make(map[trans.Language]trans.Translation)
+	ts[*ys.language] = ys
+
+	return ts
+}
+func (ys YamlString) Content() string {
+	return ys.Value
+}
+
+// Creates a new YamlDomain from the file at the given path. The file may have either a '.yaml' or
+// '.yml' extension.
+func NewFromFile(file string) (yd *YamlDomain, err error) {
+	name, lang, err := trans.InfoFromFilename(filepath.Base(file))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var root map[string]interface{}
+	err = yaml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v in file '%v'", err, file))
+	}
+
+	flat := make(map[string]string)
+	err = flatten("", root, flat)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v in file '%v'", err, file))
+	}
+
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	l := trans.Language{Code: lang}
+	yd = &YamlDomain{name: name, Language: lang}
+	for _, k := range keys {
+		yd.Messages = append(yd.Messages, &YamlString{language: &l, Key: k, Value: flat[k]})
+	}
+
+	return yd, nil
+}
+
+func flatten(prefix string, obj map[string]interface{}, out map[string]string) (err error) {
+	for k, v := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + keySeparator + k
+		}
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if err = flatten(key, v, out); err != nil {
+				return err
+			}
+			continue
+		case []interface{}:
+			return errors.New(fmt.Sprintf("Value of key '%v' is a list", key))
+		}
+
+		if _, ok := out[key]; ok {
+			return errors.New(fmt.Sprintf("Duplicate key '%v'", key))
+		}
+		if v == nil {
+			out[key] = ""
+		} else {
+			// Includes numbers and booleans, which Symfony also treats as strings
+			out[key] = fmt.Sprint(v)
+		}
+	}
+
+	return nil
+}
+
+// Exports the domain to a '[domain].[language].yaml' file for each language that it has
+// translations for. When skipSource is true, no file is written for the source language.
+func Export(source trans.Domain, sourceLang trans.Language, dir string, skipSource bool) (err error) {
+	// Create output directory
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string]map[string]string)
+
+	for _, s := range source.Strings() {
+		for l, t := range s.Translations() {
+			if _, ok := files[l.Code]; !ok {
+				files[l.Code] = make(map[string]string)
+			}
+			files[l.Code][s.Name()] = t.Content()
+		}
+	}
+
+	for code, messages := range files {
+		if skipSource && code == sourceLang.Code {
+			continue
+		}
+
+		data, err := yaml.Marshal(messages)
+		if err != nil {
+			return err
+		}
+
+		fileName := fmt.Sprintf("%v.%v.yaml", source.Name(), code)
+		err = ioutil.WriteFile(filepath.Join(dir, fileName), data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Removes previously exported YAML files from the given directory. Only files belonging to the
+// given domain and language are removed, where an empty domain or language matches any.
+func Remove(dir, domain, lang string) (err error) {
+	return trans.RemoveFiles(dir, "yaml", domain, lang)
+}